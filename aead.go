@@ -0,0 +1,199 @@
+package memguard
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// ErrInvalidKeySize is returned when a key is not of a size that is accepted by the selected cipher suite.
+var ErrInvalidKeySize = errors.New("<memguard::ErrInvalidKeySize> key size is not valid for the cipher suite")
+
+// ErrUnknownSuite is returned when an unrecognised AEADSuite is given.
+var ErrUnknownSuite = errors.New("<memguard::ErrUnknownSuite> unknown cipher suite")
+
+/*
+AEADSuite selects the authenticated encryption algorithm used by NewAEAD.
+*/
+type AEADSuite int
+
+const (
+	// AESGCM is AES in Galois/Counter Mode with a 12 byte nonce. The key must be 16, 24 or 32 bytes long.
+	AESGCM AEADSuite = iota
+
+	// ChaCha20Poly1305 is the construction from RFC 8439 with a 12 byte nonce and a 32 byte key.
+	ChaCha20Poly1305
+
+	// XChaCha20Poly1305 is the extended nonce variant of ChaCha20Poly1305 with a 24 byte nonce and a 32 byte key. Its nonces are large enough to be chosen at random.
+	XChaCha20Poly1305
+)
+
+/*
+AEADOptions configures the behaviour of an AEAD returned by NewAEADWithOptions.
+*/
+type AEADOptions struct {
+	// CacheTimeout, if positive, keeps the cipher instance alive between calls so that the key does not have to be decrypted for every operation. It is discarded once it has not been used for this long, or once the session is purged. If zero, the key is decrypted for each call and discarded immediately afterwards.
+	CacheTimeout time.Duration
+}
+
+type sealedAEAD struct {
+	key     *Enclave
+	suite   AEADSuite
+	timeout time.Duration
+
+	nonceSize int
+	overhead  int
+
+	sync.Mutex
+	cached    cipher.AEAD
+	cachedKey *LockedBuffer // key the cached instance was made from; destroyed by Purge, invalidating the cache
+	timer     *time.Timer
+}
+
+/*
+NewAEAD returns a cipher.AEAD whose key is kept sealed inside an Enclave. The key is decrypted into a LockedBuffer at the start of each call to Seal or Open and destroyed again before the call returns.
+
+The cipher implementations keep their own expanded copy of the key in ordinary memory. Sealing the key between calls means that no cipher instance holding it is kept around, but the memory of discarded instances is not wiped, so copies of the key may remain on the heap until the garbage collector reuses it.
+
+Since the cipher.AEAD interface does not allow Seal to return an error, Seal panics if the key can no longer be decrypted, for example after a call to Purge. Open returns the error instead.
+*/
+func NewAEAD(key *Enclave, suite AEADSuite) (cipher.AEAD, error) {
+	return NewAEADWithOptions(key, suite, AEADOptions{})
+}
+
+/*
+NewAEADWithOptions is like NewAEAD but allows a cache timeout to be configured. See AEADOptions for details.
+*/
+func NewAEADWithOptions(key *Enclave, suite AEADSuite, opts AEADOptions) (cipher.AEAD, error) {
+	a := &sealedAEAD{key: key, suite: suite, timeout: opts.CacheTimeout}
+
+	switch suite {
+	case AESGCM:
+		switch key.Size() {
+		case 16, 24, 32:
+		default:
+			return nil, ErrInvalidKeySize
+		}
+		a.nonceSize, a.overhead = 12, 16
+	case ChaCha20Poly1305:
+		if key.Size() != chacha20poly1305.KeySize {
+			return nil, ErrInvalidKeySize
+		}
+		a.nonceSize, a.overhead = chacha20poly1305.NonceSize, chacha20poly1305.Overhead
+	case XChaCha20Poly1305:
+		if key.Size() != chacha20poly1305.KeySize {
+			return nil, ErrInvalidKeySize
+		}
+		a.nonceSize, a.overhead = chacha20poly1305.NonceSizeX, chacha20poly1305.Overhead
+	default:
+		return nil, ErrUnknownSuite
+	}
+
+	return a, nil
+}
+
+// NonceSize returns the size of the nonce that must be passed to Seal and Open.
+func (a *sealedAEAD) NonceSize() int {
+	return a.nonceSize
+}
+
+// Overhead returns the maximum difference between the lengths of a plaintext and its ciphertext.
+func (a *sealedAEAD) Overhead() int {
+	return a.overhead
+}
+
+// Seal encrypts and authenticates plaintext, authenticates the additional data and appends the result to dst. It panics if the key cannot be decrypted.
+func (a *sealedAEAD) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	c, err := a.cipher()
+	if err != nil {
+		panic(err)
+	}
+	return c.Seal(dst, nonce, plaintext, additionalData)
+}
+
+// Open decrypts and authenticates ciphertext, authenticates the additional data and, if successful, appends the resulting plaintext to dst.
+func (a *sealedAEAD) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	c, err := a.cipher()
+	if err != nil {
+		return nil, err
+	}
+	return c.Open(dst, nonce, ciphertext, additionalData)
+}
+
+// Returns a cipher instance, either from the cache or by decrypting the key.
+func (a *sealedAEAD) cipher() (cipher.AEAD, error) {
+	if a.timeout <= 0 {
+		return a.newCipher()
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	// The key buffer is destroyed if the session has been purged since the instance was cached.
+	if a.cached != nil && !a.cachedKey.IsAlive() {
+		a.cached, a.cachedKey = nil, nil
+	}
+	if a.cached == nil {
+		k, err := a.key.Open()
+		if err != nil {
+			return nil, err
+		}
+		c, err := newAEADCipher(k.Bytes(), a.suite)
+		if err != nil {
+			k.Destroy()
+			return nil, err
+		}
+		a.cached, a.cachedKey = c, k
+	}
+
+	// Push back the expiry.
+	if a.timer == nil {
+		a.timer = time.AfterFunc(a.timeout, a.expire)
+	} else {
+		a.timer.Reset(a.timeout)
+	}
+
+	return a.cached, nil
+}
+
+// Discards the cached cipher instance.
+func (a *sealedAEAD) expire() {
+	a.Lock()
+	defer a.Unlock()
+	if a.cachedKey != nil {
+		a.cachedKey.Destroy()
+	}
+	a.cached, a.cachedKey = nil, nil
+}
+
+// Decrypts the key into a LockedBuffer and constructs a cipher instance from it.
+func (a *sealedAEAD) newCipher() (cipher.AEAD, error) {
+	k, err := a.key.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer k.Destroy()
+
+	return newAEADCipher(k.Bytes(), a.suite)
+}
+
+// Constructs a cipher instance for the given suite from a plaintext key.
+func newAEADCipher(key []byte, suite AEADSuite) (cipher.AEAD, error) {
+	switch suite {
+	case AESGCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case ChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	case XChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	}
+	return nil, ErrUnknownSuite
+}
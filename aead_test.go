@@ -0,0 +1,178 @@
+package memguard
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+	"time"
+
+	"github.com/awnumar/memguard/core"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+func TestNewAEAD(t *testing.T) {
+	suites := []struct {
+		suite AEADSuite
+		size  int
+		ref   func(key []byte) (cipher.AEAD, error)
+	}{
+		{AESGCM, 16, newGCM},
+		{AESGCM, 32, newGCM},
+		{ChaCha20Poly1305, 32, chacha20poly1305.New},
+		{XChaCha20Poly1305, 32, chacha20poly1305.NewX},
+	}
+
+	for _, s := range suites {
+		key := make([]byte, s.size)
+		ScrambleBytes(key)
+		ref, err := s.ref(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a, err := NewAEAD(NewEnclave(append([]byte{}, key...)), s.suite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.NonceSize() != ref.NonceSize() || a.Overhead() != ref.Overhead() {
+			t.Error("sizes do not match reference implementation")
+		}
+
+		nonce := make([]byte, a.NonceSize())
+		ScrambleBytes(nonce)
+		plaintext := []byte("yellow submarine")
+		ad := []byte("additional data")
+
+		ct := a.Seal(nil, nonce, plaintext, ad)
+		if !bytes.Equal(ct, ref.Seal(nil, nonce, plaintext, ad)) {
+			t.Error("ciphertext does not match reference implementation")
+		}
+		pt, err := a.Open(nil, nonce, ct, ad)
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+		if !bytes.Equal(pt, plaintext) {
+			t.Error("plaintext does not match")
+		}
+
+		ct[0] ^= 1
+		if _, err := a.Open(nil, nonce, ct, ad); err == nil {
+			t.Error("expected error opening tampered ciphertext")
+		}
+	}
+}
+
+func TestNewAEADErrors(t *testing.T) {
+	if _, err := NewAEAD(NewEnclaveRandom(20), AESGCM); err != ErrInvalidKeySize {
+		t.Error("expected ErrInvalidKeySize; got", err)
+	}
+	if _, err := NewAEAD(NewEnclaveRandom(16), ChaCha20Poly1305); err != ErrInvalidKeySize {
+		t.Error("expected ErrInvalidKeySize; got", err)
+	}
+	if _, err := NewAEAD(NewEnclaveRandom(16), XChaCha20Poly1305); err != ErrInvalidKeySize {
+		t.Error("expected ErrInvalidKeySize; got", err)
+	}
+	if _, err := NewAEAD(NewEnclaveRandom(32), AEADSuite(-1)); err != ErrUnknownSuite {
+		t.Error("expected ErrUnknownSuite; got", err)
+	}
+}
+
+func TestAEADCache(t *testing.T) {
+	a, err := NewAEADWithOptions(NewEnclaveRandom(32), XChaCha20Poly1305, AEADOptions{CacheTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := a.(*sealedAEAD)
+
+	nonce := make([]byte, a.NonceSize())
+	ct := a.Seal(nil, nonce, []byte("yellow submarine"), nil)
+
+	s.Lock()
+	cached := s.cached
+	s.Unlock()
+	if cached == nil {
+		t.Fatal("cipher was not cached")
+	}
+
+	if _, err := a.Open(nil, nonce, ct, nil); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	s.Lock()
+	if s.cached != cached {
+		t.Error("cached cipher was not reused")
+	}
+	s.Unlock()
+
+	// Wait for the cache to expire.
+	time.Sleep(200 * time.Millisecond)
+	s.Lock()
+	if s.cached != nil {
+		t.Error("cipher was not discarded after timeout")
+	}
+	s.Unlock()
+
+	// It should still work afterwards.
+	if _, err := a.Open(nil, nonce, ct, nil); err != nil {
+		t.Error("unexpected error:", err)
+	}
+
+	// Without a cache nothing should be retained.
+	a, err = NewAEAD(NewEnclaveRandom(32), XChaCha20Poly1305)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Seal(nil, nonce, []byte("yellow submarine"), nil)
+	if a.(*sealedAEAD).cached != nil {
+		t.Error("cipher should not be cached")
+	}
+
+	// Purging the session discards the cache.
+	a, err = NewAEADWithOptions(NewEnclaveRandom(32), XChaCha20Poly1305, AEADOptions{CacheTimeout: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct = a.Seal(nil, nonce, []byte("yellow submarine"), nil)
+	Purge()
+	if _, err := a.Open(nil, nonce, ct, nil); err != core.ErrDecryptionFailed {
+		t.Error("expected ErrDecryptionFailed; got", err)
+	}
+	if a.(*sealedAEAD).cached != nil {
+		t.Error("cipher should have been discarded")
+	}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func TestAEADSealPurged(t *testing.T) {
+	a, err := NewAEAD(NewEnclaveRandom(32), ChaCha20Poly1305)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Purge()
+
+	// Seal should panic with the error, without tearing down the rest of the session.
+	b := NewBufferRandom(32)
+	defer b.Destroy()
+	func() {
+		defer func() {
+			if r := recover(); r != core.ErrDecryptionFailed {
+				t.Error("expected panic with ErrDecryptionFailed; got", r)
+			}
+		}()
+		a.Seal(nil, make([]byte, a.NonceSize()), []byte("yellow submarine"), nil)
+	}()
+	if !b.IsAlive() {
+		t.Error("buffer should not have been destroyed")
+	}
+
+	if _, err := a.Open(nil, make([]byte, a.NonceSize()), make([]byte, 32), nil); err != core.ErrDecryptionFailed {
+		t.Error("expected ErrDecryptionFailed; got", err)
+	}
+}
@@ -358,6 +358,31 @@ func (b *LockedBuffer) EqualTo(buf []byte) bool {
 	return core.Equal(b.Bytes(), buf)
 }
 
+/*
+Equal performs a time-constant comparison on the contents of two LockedBuffers. The comparison does not terminate early if the sizes of the buffers differ, so timing does not reveal whether they are the same size, although the time taken grows with the size of each buffer. If either LockedBuffer has been destroyed, false is returned.
+*/
+func (b *LockedBuffer) Equal(other *LockedBuffer) bool {
+	if !b.IsAlive() || !other.IsAlive() {
+		return false
+	}
+
+	// Acquire the locks in a consistent order, so that concurrent calls with the arguments swapped cannot deadlock.
+	first, second := b.Buffer, other.Buffer
+	if uintptr(unsafe.Pointer(first)) > uintptr(unsafe.Pointer(second)) {
+		first, second = second, first
+	}
+	first.RLock()
+	defer first.RUnlock()
+
+	// Avoid acquiring a second read lock on the same mutex.
+	if first != second {
+		second.RLock()
+		defer second.RUnlock()
+	}
+
+	return core.EqualHashed(b.Bytes(), other.Bytes())
+}
+
 /*
 	Functions for representing the memory region as various data types.
 */
@@ -376,6 +401,26 @@ func (b *LockedBuffer) Reader() *bytes.Reader {
 	return bytes.NewReader(b.Bytes())
 }
 
+/*
+WriteTo writes the contents of a LockedBuffer to w directly from the protected region of memory, implementing io.WriterTo. This means that io.Copy never has to copy the data into an unprotected intermediate buffer. The writer must not retain the slice it is given.
+
+If called on a destroyed LockedBuffer, nothing is written.
+*/
+func (b *LockedBuffer) WriteTo(w io.Writer) (int64, error) {
+	b.RLock()
+	defer b.RUnlock()
+
+	data := b.Bytes()
+	if len(data) == 0 {
+		return 0, nil
+	}
+	n, err := w.Write(data)
+	if err == nil && n < len(data) {
+		err = io.ErrShortWrite
+	}
+	return int64(n), err
+}
+
 /*
 String returns a string representation of the protected region of memory.
 */
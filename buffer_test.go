@@ -8,7 +8,9 @@ import (
 	mrand "math/rand"
 	"os"
 	"runtime"
+	"sync"
 	"testing"
+	"time"
 	"unsafe"
 )
 
@@ -853,6 +855,69 @@ func TestEqualTo(t *testing.T) {
 	}
 }
 
+func TestEqual(t *testing.T) {
+	a := NewBufferFromBytes([]byte("yellow submarine"))
+	b := NewBufferFromBytes([]byte("yellow submarine"))
+	if !a.Equal(b) || !b.Equal(a) {
+		t.Error("comparison incorrect")
+	}
+	if !a.Equal(a) {
+		t.Error("buffer should equal itself")
+	}
+	c := NewBufferFromBytes([]byte("yellow"))
+	if a.Equal(c) || c.Equal(a) {
+		t.Error("comparison incorrect with differing lengths")
+	}
+	d := NewBufferFromBytes([]byte("yellow submarinf"))
+	if a.Equal(d) {
+		t.Error("comparison incorrect")
+	}
+	b.Destroy()
+	if a.Equal(b) || b.Equal(a) {
+		t.Error("comparison with destroyed should be false")
+	}
+	if newNullBuffer().Equal(newNullBuffer()) {
+		t.Error("comparison of null buffers should be false")
+	}
+	a.Destroy()
+	c.Destroy()
+	d.Destroy()
+}
+
+func TestEqualConcurrent(t *testing.T) {
+	a := NewBufferFromBytes([]byte("yellow submarine"))
+	b := NewBufferFromBytes([]byte("yellow submarine"))
+	defer a.Destroy()
+	defer b.Destroy()
+
+	// Compare in both directions while writers contend for the locks.
+	var wg sync.WaitGroup
+	run := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				f()
+			}
+		}()
+	}
+	run(func() { a.Equal(b) })
+	run(func() { b.Equal(a) })
+	run(func() { a.Melt(); a.Freeze() })
+	run(func() { b.Melt(); b.Freeze() })
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("deadlock comparing buffers")
+	}
+}
+
 func TestBytes(t *testing.T) {
 	b := NewBufferFromBytes([]byte("yellow submarine"))
 	if b == nil {
@@ -896,6 +961,40 @@ func TestReader(t *testing.T) {
 	}
 }
 
+func TestBufferWriteTo(t *testing.T) {
+	b := NewBufferRandom(32)
+	var out bytes.Buffer
+	n, err := b.WriteTo(&out)
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 32 || !b.EqualTo(out.Bytes()) {
+		t.Error("data not equal")
+	}
+
+	// Short writes are reported.
+	if n, err := b.WriteTo(&shortWriter{limit: 10}); n != 10 || err != io.ErrShortWrite {
+		t.Error("expected short write; got", n, err)
+	}
+
+	b.Destroy()
+	out.Reset()
+	if n, err := b.WriteTo(&out); n != 0 || err != nil || out.Len() != 0 {
+		t.Error("expected nothing to be written from destroyed buffer")
+	}
+}
+
+// Accepts at most limit bytes, silently dropping the rest.
+type shortWriter struct {
+	limit int
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	n := min(len(p), w.limit)
+	w.limit -= n
+	return n, nil
+}
+
 func TestString(t *testing.T) {
 	b := NewBufferRandom(32)
 	b.Melt()
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// blake2bState is an implementation of keyed BLAKE2b that keeps all of its state inside caller provided memory. Only lengths, which are not secret, are held elsewhere.
+type blake2bState struct {
+	h   []byte // chaining value, 8 words
+	v   []byte // working vector, 16 words
+	m   []byte // message block, 16 words
+	buf []byte // pending block
+	n   int    // number of bytes in buf
+	t   [2]uint64
+}
+
+const (
+	blake2bBlockSize  = 128
+	blake2bMaxKey     = 64
+	blake2bScratchLen = 64 + 128 + 128 + blake2bBlockSize
+)
+
+var blake2bIV = [8]uint64{
+	0x6a09e667f3bcc908, 0xbb67ae8584caa73b, 0x3c6ef372fe94f82b, 0xa54ff53a5f1d36f1,
+	0x510e527fade682d1, 0x9b05688c2b3e6c1f, 0x1f83d9abfb41bd6b, 0x5be0cd19137e2179,
+}
+
+var blake2bSigma = [12][16]byte{
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+	{14, 10, 4, 8, 9, 15, 13, 6, 1, 12, 0, 2, 11, 7, 5, 3},
+	{11, 8, 12, 0, 5, 2, 15, 13, 10, 14, 3, 6, 7, 1, 9, 4},
+	{7, 9, 3, 1, 13, 12, 11, 14, 2, 6, 5, 10, 4, 0, 15, 8},
+	{9, 0, 5, 7, 2, 4, 10, 15, 14, 1, 11, 12, 6, 8, 3, 13},
+	{2, 12, 6, 10, 0, 11, 8, 3, 4, 13, 7, 5, 15, 14, 1, 9},
+	{12, 5, 1, 15, 14, 13, 4, 10, 0, 7, 6, 3, 9, 2, 8, 11},
+	{13, 11, 7, 14, 12, 1, 3, 9, 5, 0, 15, 4, 8, 6, 2, 10},
+	{6, 15, 14, 9, 11, 3, 0, 8, 12, 2, 13, 7, 1, 4, 10, 5},
+	{10, 2, 8, 4, 7, 6, 1, 5, 15, 11, 9, 14, 3, 12, 13, 0},
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+	{14, 10, 4, 8, 9, 15, 13, 6, 1, 12, 0, 2, 11, 7, 5, 3},
+}
+
+// Initialises a keyed BLAKE2b state producing size bytes of output inside the given scratch space, which must be at least blake2bScratchLen bytes long. The key must be between 1 and blake2bMaxKey bytes long.
+func newBlake2b(scratch, key []byte, size int) *blake2bState {
+	s := &blake2bState{
+		h:   scratch[0:64],
+		v:   scratch[64:192],
+		m:   scratch[192:320],
+		buf: scratch[320:blake2bScratchLen],
+	}
+	for i, v := range blake2bIV {
+		binary.LittleEndian.PutUint64(s.h[8*i:], v)
+	}
+	p := binary.LittleEndian.Uint64(s.h) ^ uint64(size) ^ uint64(len(key))<<8 ^ 0x01010000
+	binary.LittleEndian.PutUint64(s.h, p)
+
+	// The key is padded to a full block and processed as the first block of the message.
+	Wipe(s.buf)
+	copy(s.buf, key)
+	s.n = blake2bBlockSize
+	return s
+}
+
+func (s *blake2bState) write(p []byte) {
+	for len(p) > 0 {
+		// Only compress a full block once we know that it is not the last one.
+		if s.n == blake2bBlockSize {
+			s.increment(blake2bBlockSize)
+			s.block(s.buf, false)
+			s.n = 0
+		}
+		c := copy(s.buf[s.n:], p)
+		s.n += c
+		p = p[c:]
+	}
+}
+
+// Writes the digest into out, which must be the size given to newBlake2b. The state must not be used afterwards.
+func (s *blake2bState) sum(out []byte) {
+	Wipe(s.buf[s.n:])
+	s.increment(uint64(s.n))
+	s.block(s.buf, true)
+
+	// The chaining value is stored little-endian, so it is already in output order.
+	copy(out, s.h)
+}
+
+func (s *blake2bState) increment(n uint64) {
+	var carry uint64
+	s.t[0], carry = bits.Add64(s.t[0], n, 0)
+	s.t[1] += carry
+}
+
+func (s *blake2bState) block(p []byte, last bool) {
+	copy(s.m, p)
+
+	copy(s.v[:64], s.h)
+	for i, iv := range blake2bIV {
+		binary.LittleEndian.PutUint64(s.v[64+8*i:], iv)
+	}
+	s.xor(12, s.t[0])
+	s.xor(13, s.t[1])
+	if last {
+		s.xor(14, ^uint64(0))
+	}
+
+	for r := 0; r < 12; r++ {
+		sigma := &blake2bSigma[r]
+		s.g(0, 4, 8, 12, sigma[0], sigma[1])
+		s.g(1, 5, 9, 13, sigma[2], sigma[3])
+		s.g(2, 6, 10, 14, sigma[4], sigma[5])
+		s.g(3, 7, 11, 15, sigma[6], sigma[7])
+		s.g(0, 5, 10, 15, sigma[8], sigma[9])
+		s.g(1, 6, 11, 12, sigma[10], sigma[11])
+		s.g(2, 7, 8, 13, sigma[12], sigma[13])
+		s.g(3, 4, 9, 14, sigma[14], sigma[15])
+	}
+
+	for i := 0; i < 8; i++ {
+		h := binary.LittleEndian.Uint64(s.h[8*i:])
+		h ^= binary.LittleEndian.Uint64(s.v[8*i:]) ^ binary.LittleEndian.Uint64(s.v[64+8*i:])
+		binary.LittleEndian.PutUint64(s.h[8*i:], h)
+	}
+}
+
+// The BLAKE2b mixing function, operating on words of the working vector and message block.
+func (s *blake2bState) g(a, b, c, d int, x, y byte) {
+	va := binary.LittleEndian.Uint64(s.v[8*a:])
+	vb := binary.LittleEndian.Uint64(s.v[8*b:])
+	vc := binary.LittleEndian.Uint64(s.v[8*c:])
+	vd := binary.LittleEndian.Uint64(s.v[8*d:])
+
+	va += vb + binary.LittleEndian.Uint64(s.m[8*int(x):])
+	vd = bits.RotateLeft64(vd^va, -32)
+	vc += vd
+	vb = bits.RotateLeft64(vb^vc, -24)
+	va += vb + binary.LittleEndian.Uint64(s.m[8*int(y):])
+	vd = bits.RotateLeft64(vd^va, -16)
+	vc += vd
+	vb = bits.RotateLeft64(vb^vc, -63)
+
+	binary.LittleEndian.PutUint64(s.v[8*a:], va)
+	binary.LittleEndian.PutUint64(s.v[8*b:], vb)
+	binary.LittleEndian.PutUint64(s.v[8*c:], vc)
+	binary.LittleEndian.PutUint64(s.v[8*d:], vd)
+}
+
+func (s *blake2bState) xor(i int, x uint64) {
+	binary.LittleEndian.PutUint64(s.v[8*i:], binary.LittleEndian.Uint64(s.v[8*i:])^x)
+}
@@ -129,3 +129,38 @@ func Move(dst, src []byte) {
 func Equal(x, y []byte) bool {
 	return subtle.ConstantTimeCompare(x, y) == 1
 }
+
+/*
+EqualHashed does a constant-time comparison of two byte slices whose lengths may differ. Equal returns early when the lengths do not match, which leaks that fact through timing. Here both slices are instead hashed under a fresh random key and the fixed-size digests are compared.
+
+The key and the digests are held inside a Buffer that is destroyed before the function returns.
+*/
+func EqualHashed(x, y []byte) bool {
+	// Allocate space for the key and both digests.
+	b, err := NewBuffer(32 + 2*blake2b.Size256)
+	if err != nil {
+		Panic(err)
+	}
+	defer b.Destroy()
+
+	key := b.Data()[:32]
+	dx := b.Data()[32 : 32+blake2b.Size256]
+	dy := b.Data()[32+blake2b.Size256:]
+
+	// Generate the ephemeral hashing key.
+	if err := Scramble(key); err != nil {
+		Panic(err)
+	}
+
+	// Hash each input, writing the digests directly into the guarded allocation.
+	for _, v := range []struct{ in, out []byte }{{x, dx}, {y, dy}} {
+		h, err := blake2b.New256(key)
+		if err != nil {
+			Panic(err) // key is longer than 64 bytes
+		}
+		h.Write(v.in)
+		h.Sum(v.out[:0])
+	}
+
+	return Equal(dx, dy)
+}
@@ -219,3 +219,18 @@ func TestEncryptDecrypt(t *testing.T) {
 		t.Error("expected error with invalid key; got", err)
 	}
 }
+
+func TestEqualHashed(t *testing.T) {
+	if !EqualHashed([]byte("yellow submarine"), []byte("yellow submarine")) {
+		t.Error("comparison incorrect")
+	}
+	if EqualHashed([]byte("yellow submarine"), []byte("yellow submarinf")) {
+		t.Error("comparison incorrect")
+	}
+	if EqualHashed([]byte("yellow submarine"), []byte("yellow")) {
+		t.Error("comparison incorrect with differing lengths")
+	}
+	if !EqualHashed(nil, []byte{}) {
+		t.Error("empty inputs should be equal")
+	}
+}
@@ -136,3 +136,12 @@ EnclaveSize returns the number of bytes of plaintext data stored inside an Encla
 func EnclaveSize(e *Enclave) int {
 	return len(e.ciphertext) - Overhead
 }
+
+/*
+WipeEnclave overwrites the ciphertext held by an Enclave with zeroes, so that its contents cannot be recovered even while the session key is alive. Subsequent attempts to open the Enclave fail with ErrDecryptionFailed.
+
+The Enclave must not be in use by any other goroutine, and must not be shared with anything that still needs it.
+*/
+func WipeEnclave(e *Enclave) {
+	Wipe(e.ciphertext)
+}
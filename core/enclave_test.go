@@ -120,3 +120,17 @@ func TestEnclaveSize(t *testing.T) {
 		t.Error("invalid enclave size")
 	}
 }
+
+func TestWipeEnclave(t *testing.T) {
+	e, err := NewEnclave([]byte("yellow submarine"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	WipeEnclave(e)
+	if !bytes.Equal(e.ciphertext, make([]byte, len(e.ciphertext))) {
+		t.Error("ciphertext was not wiped")
+	}
+	if _, err := Open(e); err != ErrDecryptionFailed {
+		t.Error("expected ErrDecryptionFailed; got", err)
+	}
+}
@@ -0,0 +1,306 @@
+package core
+
+import (
+	"errors"
+)
+
+/*
+The functions in this file convert between binary data and its textual representations without using table lookups or branches that depend on the value of the data. The arithmetic used for each character is adapted from the approach taken by the paragonie/constant_time_encoding project.
+
+Branches are taken only on lengths and on the position of padding characters, both of which are implied by the size of the decoded output and are not considered secret.
+*/
+
+// ErrInvalidEncoding is returned when attempting to decode data that is not validly encoded. The position of the offending character is deliberately not reported.
+var ErrInvalidEncoding = errors.New("<memguard::core::ErrInvalidEncoding> input is not validly encoded")
+
+// HexEncodedLen returns the length of the hex encoding of n bytes of data.
+func HexEncodedLen(n int) int {
+	return n * 2
+}
+
+// HexDecodedLen returns the length of the data represented by n hex characters.
+func HexDecodedLen(n int) int {
+	return n / 2
+}
+
+// HexEncode writes the lowercase hex encoding of src into dst, which must be HexEncodedLen(len(src)) bytes long.
+func HexEncode(dst, src []byte) {
+	for i, v := range src {
+		dst[2*i] = hexEncodeNibble(int(v >> 4))
+		dst[2*i+1] = hexEncodeNibble(int(v & 0x0f))
+	}
+}
+
+/*
+HexDecode decodes the hex encoded src into dst, which must be at least HexDecodedLen(len(src)) bytes long. Both upper and lower case characters are accepted.
+
+The number of bytes written is returned. If the input is not valid hex, ErrInvalidEncoding is returned and the contents of dst are unspecified.
+*/
+func HexDecode(dst, src []byte) (int, error) {
+	if len(src)%2 != 0 {
+		return 0, ErrInvalidEncoding
+	}
+
+	var invalid int
+	for i := 0; i < len(src)/2; i++ {
+		hi := hexDecodeChar(int(src[2*i]))
+		lo := hexDecodeChar(int(src[2*i+1]))
+		invalid |= hi | lo
+		dst[i] = byte(hi<<4 | lo)
+	}
+
+	// Any character outside of the alphabet will have set the sign bit.
+	if invalid < 0 {
+		return 0, ErrInvalidEncoding
+	}
+	return len(src) / 2, nil
+}
+
+// Base64EncodedLen returns the length of the padded standard base64 encoding of n bytes of data.
+func Base64EncodedLen(n int) int {
+	return (n + 2) / 3 * 4
+}
+
+// Base64DecodedLen returns the maximum length of the data represented by n characters of padded standard base64.
+func Base64DecodedLen(n int) int {
+	return n / 4 * 3
+}
+
+// Base64Encode writes the padded standard base64 encoding of src into dst, which must be Base64EncodedLen(len(src)) bytes long.
+func Base64Encode(dst, src []byte) {
+	di, si := 0, 0
+	for ; si+3 <= len(src); si, di = si+3, di+4 {
+		v := int(src[si])<<16 | int(src[si+1])<<8 | int(src[si+2])
+		dst[di] = base64EncodeSextet(v >> 18 & 0x3f)
+		dst[di+1] = base64EncodeSextet(v >> 12 & 0x3f)
+		dst[di+2] = base64EncodeSextet(v >> 6 & 0x3f)
+		dst[di+3] = base64EncodeSextet(v & 0x3f)
+	}
+
+	switch len(src) - si {
+	case 2:
+		v := int(src[si])<<16 | int(src[si+1])<<8
+		dst[di] = base64EncodeSextet(v >> 18 & 0x3f)
+		dst[di+1] = base64EncodeSextet(v >> 12 & 0x3f)
+		dst[di+2] = base64EncodeSextet(v >> 6 & 0x3f)
+		dst[di+3] = '='
+	case 1:
+		v := int(src[si]) << 16
+		dst[di] = base64EncodeSextet(v >> 18 & 0x3f)
+		dst[di+1] = base64EncodeSextet(v >> 12 & 0x3f)
+		dst[di+2] = '='
+		dst[di+3] = '='
+	}
+}
+
+/*
+Base64Decode decodes the padded standard base64 encoded src into dst, which must be at least Base64DecodedLen(len(src)) bytes long.
+
+The number of bytes written is returned. If the input is not valid base64, ErrInvalidEncoding is returned and the contents of dst are unspecified.
+*/
+func Base64Decode(dst, src []byte) (int, error) {
+	if len(src)%4 != 0 {
+		return 0, ErrInvalidEncoding
+	}
+	if len(src) == 0 {
+		return 0, nil
+	}
+
+	// Work out how many padding characters there are.
+	pad := 0
+	if src[len(src)-1] == '=' {
+		pad++
+		if src[len(src)-2] == '=' {
+			pad++
+		}
+	}
+
+	var invalid, v int
+	di := 0
+	body := src[:len(src)-pad]
+	for si := 0; si < len(body); si++ {
+		c := base64DecodeChar(int(body[si]))
+		invalid |= c
+		v = v<<6 | c&0x3f
+
+		if si%4 == 3 {
+			dst[di] = byte(v >> 16)
+			dst[di+1] = byte(v >> 8)
+			dst[di+2] = byte(v)
+			di += 3
+			v = 0
+		}
+	}
+
+	// Handle the final partial quantum.
+	switch pad {
+	case 1:
+		dst[di] = byte(v >> 10)
+		dst[di+1] = byte(v >> 2)
+		di += 2
+	case 2:
+		dst[di] = byte(v >> 4)
+		di++
+	}
+
+	if invalid < 0 {
+		return 0, ErrInvalidEncoding
+	}
+	return di, nil
+}
+
+// Base32EncodedLen returns the length of the padded standard base32 encoding of n bytes of data.
+func Base32EncodedLen(n int) int {
+	return (n + 4) / 5 * 8
+}
+
+// Base32DecodedLen returns the maximum length of the data represented by n characters of padded standard base32.
+func Base32DecodedLen(n int) int {
+	return n / 8 * 5
+}
+
+// Base32Encode writes the padded standard base32 encoding of src into dst, which must be Base32EncodedLen(len(src)) bytes long.
+func Base32Encode(dst, src []byte) {
+	for si, di := 0, 0; si < len(src); si, di = si+5, di+8 {
+		// Gather up to five bytes into a 40 bit block.
+		n := len(src) - si
+		if n > 5 {
+			n = 5
+		}
+		var v uint64
+		for i := 0; i < 5; i++ {
+			v <<= 8
+			if i < n {
+				v |= uint64(src[si+i])
+			}
+		}
+
+		// Number of characters that carry data for this block.
+		chars := (n*8 + 4) / 5
+		for i := 0; i < 8; i++ {
+			if i < chars {
+				dst[di+i] = base32EncodeQuintet(int(v >> (35 - 5*uint(i)) & 0x1f))
+			} else {
+				dst[di+i] = '='
+			}
+		}
+	}
+}
+
+/*
+Base32Decode decodes the padded standard base32 encoded src into dst, which must be at least Base32DecodedLen(len(src)) bytes long.
+
+The number of bytes written is returned. If the input is not valid base32, ErrInvalidEncoding is returned and the contents of dst are unspecified.
+*/
+func Base32Decode(dst, src []byte) (int, error) {
+	if len(src)%8 != 0 {
+		return 0, ErrInvalidEncoding
+	}
+	if len(src) == 0 {
+		return 0, nil
+	}
+
+	// Work out how many padding characters there are.
+	pad := 0
+	for pad < 6 && src[len(src)-1-pad] == '=' {
+		pad++
+	}
+
+	// Map the amount of padding to the number of bytes in the final block.
+	var tail int
+	switch pad {
+	case 0:
+		tail = 5
+	case 1:
+		tail = 4
+	case 3:
+		tail = 3
+	case 4:
+		tail = 2
+	case 6:
+		tail = 1
+	default:
+		return 0, ErrInvalidEncoding
+	}
+
+	var invalid int
+	di := 0
+	for si := 0; si < len(src); si += 8 {
+		last := si+8 == len(src)
+
+		var v uint64
+		for i := 0; i < 8; i++ {
+			v <<= 5
+			if last && i >= 8-pad {
+				continue
+			}
+			c := base32DecodeChar(int(src[si+i]))
+			invalid |= c
+			v |= uint64(c & 0x1f)
+		}
+
+		n := 5
+		if last {
+			n = tail
+		}
+		for i := 0; i < n; i++ {
+			dst[di+i] = byte(v >> (32 - 8*uint(i)))
+		}
+		di += n
+	}
+
+	if invalid < 0 {
+		return 0, ErrInvalidEncoding
+	}
+	return di, nil
+}
+
+// Maps 0..15 onto '0'..'9', 'a'..'f'.
+func hexEncodeNibble(n int) byte {
+	return byte(n + 0x30 + ((9-n)>>8)&0x27)
+}
+
+// Maps a hex character onto its value, or -1 if it is not in the alphabet.
+func hexDecodeChar(c int) int {
+	ret := -1
+	ret += (((0x2f - c) & (c - 0x3a)) >> 8) & (c - 0x2f) // '0'..'9'
+	ret += (((0x40 - c) & (c - 0x47)) >> 8) & (c - 0x36) // 'A'..'F'
+	ret += (((0x60 - c) & (c - 0x67)) >> 8) & (c - 0x56) // 'a'..'f'
+	return ret
+}
+
+// Maps 0..63 onto the standard base64 alphabet.
+func base64EncodeSextet(n int) byte {
+	diff := 0x41
+	diff += ((25 - n) >> 8) & 6
+	diff -= ((51 - n) >> 8) & 75
+	diff -= ((61 - n) >> 8) & 15
+	diff += ((62 - n) >> 8) & 3
+	return byte(n + diff)
+}
+
+// Maps a standard base64 character onto its value, or -1 if it is not in the alphabet.
+func base64DecodeChar(c int) int {
+	ret := -1
+	ret += (((0x40 - c) & (c - 0x5b)) >> 8) & (c - 0x40) // 'A'..'Z'
+	ret += (((0x60 - c) & (c - 0x7b)) >> 8) & (c - 0x46) // 'a'..'z'
+	ret += (((0x2f - c) & (c - 0x3a)) >> 8) & (c + 0x05) // '0'..'9'
+	ret += (((0x2a - c) & (c - 0x2c)) >> 8) & 0x3f       // '+'
+	ret += (((0x2e - c) & (c - 0x30)) >> 8) & 0x40       // '/'
+	return ret
+}
+
+// Maps 0..31 onto the standard base32 alphabet.
+func base32EncodeQuintet(n int) byte {
+	diff := 0x41
+	diff -= ((25 - n) >> 8) & 41
+	return byte(n + diff)
+}
+
+// Maps a standard base32 character onto its value, or -1 if it is not in the alphabet.
+func base32DecodeChar(c int) int {
+	ret := -1
+	ret += (((0x40 - c) & (c - 0x5b)) >> 8) & (c - 0x40) // 'A'..'Z'
+	ret += (((0x31 - c) & (c - 0x38)) >> 8) & (c - 0x17) // '2'..'7'
+	return ret
+}
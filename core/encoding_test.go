@@ -0,0 +1,141 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+func TestHex(t *testing.T) {
+	for size := 0; size < 64; size++ {
+		data := make([]byte, size)
+		Scramble(data)
+
+		enc := make([]byte, HexEncodedLen(size))
+		HexEncode(enc, data)
+		if !bytes.Equal(enc, []byte(hex.EncodeToString(data))) {
+			t.Error("encoding mismatch for size", size)
+		}
+
+		dec := make([]byte, HexDecodedLen(len(enc)))
+		n, err := HexDecode(dec, enc)
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+		if !bytes.Equal(dec[:n], data) {
+			t.Error("decoding mismatch for size", size)
+		}
+
+		// Upper case should be accepted too.
+		n, err = HexDecode(dec, bytes.ToUpper(enc))
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+		if !bytes.Equal(dec[:n], data) {
+			t.Error("decoding mismatch for upper case with size", size)
+		}
+	}
+
+	for _, s := range []string{"0", "0g", "zz", "0:", "@0", "`0", "G0"} {
+		if _, err := HexDecode(make([]byte, 2), []byte(s)); err != ErrInvalidEncoding {
+			t.Error("expected ErrInvalidEncoding for", s, "got", err)
+		}
+	}
+}
+
+func TestBase64(t *testing.T) {
+	for size := 0; size < 64; size++ {
+		data := make([]byte, size)
+		Scramble(data)
+
+		enc := make([]byte, Base64EncodedLen(size))
+		Base64Encode(enc, data)
+		if !bytes.Equal(enc, []byte(base64.StdEncoding.EncodeToString(data))) {
+			t.Error("encoding mismatch for size", size)
+		}
+
+		dec := make([]byte, Base64DecodedLen(len(enc)))
+		n, err := Base64Decode(dec, enc)
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+		if !bytes.Equal(dec[:n], data) {
+			t.Error("decoding mismatch for size", size)
+		}
+	}
+
+	for _, s := range []string{"A", "AAA", "A===", "AA=A", "AA-A", "AA_A", "AA\nA", "AAA.", "=AAA"} {
+		if _, err := Base64Decode(make([]byte, 3), []byte(s)); err != ErrInvalidEncoding {
+			t.Error("expected ErrInvalidEncoding for", s, "got", err)
+		}
+	}
+}
+
+func TestBase32(t *testing.T) {
+	for size := 0; size < 64; size++ {
+		data := make([]byte, size)
+		Scramble(data)
+
+		enc := make([]byte, Base32EncodedLen(size))
+		Base32Encode(enc, data)
+		if !bytes.Equal(enc, []byte(base32.StdEncoding.EncodeToString(data))) {
+			t.Error("encoding mismatch for size", size)
+		}
+
+		dec := make([]byte, Base32DecodedLen(len(enc)))
+		n, err := Base32Decode(dec, enc)
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+		if !bytes.Equal(dec[:n], data) {
+			t.Error("decoding mismatch for size", size)
+		}
+	}
+
+	for _, s := range []string{"AAAA", "AAAAAA==", "AAA=====", "A=======", "AAAAAAA1", "AAAAAAA8", "aaaaaaaa", "AAA=AAAA"} {
+		if _, err := Base32Decode(make([]byte, 5), []byte(s)); err != ErrInvalidEncoding {
+			t.Error("expected ErrInvalidEncoding for", s, "got", err)
+		}
+	}
+}
+
+func TestEncodingAlphabets(t *testing.T) {
+	// Exhaustively check every byte value against the reference alphabets.
+	const hexAlphabet = "0123456789abcdef"
+	const b64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+	const b32Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+
+	for c := 0; c < 256; c++ {
+		want := bytes.IndexByte([]byte(hexAlphabet), byte(c))
+		if want == -1 {
+			want = bytes.IndexByte([]byte("0123456789ABCDEF"), byte(c))
+		}
+		if got := hexDecodeChar(c); got != want {
+			t.Errorf("hex: char %#x decoded to %d; want %d", c, got, want)
+		}
+		if got := base64DecodeChar(c); got != bytes.IndexByte([]byte(b64Alphabet), byte(c)) {
+			t.Errorf("base64: char %#x decoded to %d", c, got)
+		}
+		if got := base32DecodeChar(c); got != bytes.IndexByte([]byte(b32Alphabet), byte(c)) {
+			t.Errorf("base32: char %#x decoded to %d", c, got)
+		}
+	}
+
+	for i := range hexAlphabet {
+		if hexEncodeNibble(i) != hexAlphabet[i] {
+			t.Error("hex: incorrect encoding for", i)
+		}
+	}
+	for i := range b64Alphabet {
+		if base64EncodeSextet(i) != b64Alphabet[i] {
+			t.Error("base64: incorrect encoding for", i)
+		}
+	}
+	for i := range b32Alphabet {
+		if base32EncodeQuintet(i) != b32Alphabet[i] {
+			t.Error("base32: incorrect encoding for", i)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"errors"
+)
+
+// MACSize is the size of the tags produced by HMACSHA256 and Blake2bMAC.
+const MACSize = 32
+
+// MACScratchSize is the number of bytes of scratch space needed by HMACSHA256 and Blake2bMAC. All of the key-dependent state of the computation is kept in this space, so it should be allocated within a Buffer.
+const MACScratchSize = sha256ScratchLen + sha256BlockSize + sha256Size
+
+// ErrInvalidMACKey is returned when a key given to Blake2bMAC is empty or longer than 64 bytes.
+var ErrInvalidMACKey = errors.New("<memguard::core::ErrInvalidMACKey> BLAKE2b keys must be between 1 and 64 bytes")
+
+/*
+HMACSHA256 computes the HMAC-SHA256 of msg under key and writes the tag into dst, which must be at least MACSize bytes long. The scratch slice must be at least MACScratchSize bytes long and holds the padded key and every hash state derived from it. It is not wiped by this function.
+*/
+func HMACSHA256(dst, key, msg, scratch []byte) {
+	keyBlock := scratch[sha256ScratchLen : sha256ScratchLen+sha256BlockSize]
+	inner := scratch[sha256ScratchLen+sha256BlockSize : MACScratchSize]
+
+	// Keys longer than a block are hashed first.
+	Wipe(keyBlock)
+	if len(key) > sha256BlockSize {
+		s := newSHA256(scratch)
+		s.write(key)
+		s.sum(keyBlock)
+	} else {
+		copy(keyBlock, key)
+	}
+
+	// Inner hash: H((K ^ ipad) || msg)
+	for i := range keyBlock {
+		keyBlock[i] ^= 0x36
+	}
+	s := newSHA256(scratch)
+	s.write(keyBlock)
+	s.write(msg)
+	s.sum(inner)
+
+	// Outer hash: H((K ^ opad) || inner)
+	for i := range keyBlock {
+		keyBlock[i] ^= 0x36 ^ 0x5c
+	}
+	s = newSHA256(scratch)
+	s.write(keyBlock)
+	s.write(inner)
+	s.sum(dst)
+}
+
+/*
+Blake2bMAC computes the keyed BLAKE2b-256 hash of msg and writes the tag into dst, which must be at least MACSize bytes long. The key must be between 1 and 64 bytes long. The scratch slice must be at least MACScratchSize bytes long and holds every hash state derived from the key. It is not wiped by this function.
+*/
+func Blake2bMAC(dst, key, msg, scratch []byte) error {
+	if len(key) < 1 || len(key) > blake2bMaxKey {
+		return ErrInvalidMACKey
+	}
+	s := newBlake2b(scratch, key, MACSize)
+	s.write(msg)
+	s.sum(dst[:MACSize])
+	return nil
+}
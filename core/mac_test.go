@@ -0,0 +1,66 @@
+package core
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestHMACSHA256(t *testing.T) {
+	scratch := make([]byte, MACScratchSize)
+	tag := make([]byte, MACSize)
+
+	for _, keySize := range []int{1, 16, 32, 63, 64, 65, 128, 200} {
+		key := make([]byte, keySize)
+		Scramble(key)
+		for _, msgSize := range []int{0, 1, 55, 56, 63, 64, 65, 119, 120, 128, 1000} {
+			msg := make([]byte, msgSize)
+			Scramble(msg)
+
+			HMACSHA256(tag, key, msg, scratch)
+
+			ref := hmac.New(sha256.New, key)
+			ref.Write(msg)
+			if !bytes.Equal(tag, ref.Sum(nil)) {
+				t.Errorf("tag mismatch for key size %d and message size %d", keySize, msgSize)
+			}
+		}
+	}
+}
+
+func TestBlake2bMAC(t *testing.T) {
+	scratch := make([]byte, MACScratchSize)
+	tag := make([]byte, MACSize)
+
+	for _, keySize := range []int{1, 16, 32, 64} {
+		key := make([]byte, keySize)
+		Scramble(key)
+		for _, msgSize := range []int{0, 1, 127, 128, 129, 255, 256, 257, 1000} {
+			msg := make([]byte, msgSize)
+			Scramble(msg)
+
+			if err := Blake2bMAC(tag, key, msg, scratch); err != nil {
+				t.Fatal(err)
+			}
+
+			ref, err := blake2b.New256(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ref.Write(msg)
+			if !bytes.Equal(tag, ref.Sum(nil)) {
+				t.Errorf("tag mismatch for key size %d and message size %d", keySize, msgSize)
+			}
+		}
+	}
+
+	if err := Blake2bMAC(tag, nil, nil, scratch); err != ErrInvalidMACKey {
+		t.Error("expected ErrInvalidMACKey; got", err)
+	}
+	if err := Blake2bMAC(tag, make([]byte, 65), nil, scratch); err != ErrInvalidMACKey {
+		t.Error("expected ErrInvalidMACKey; got", err)
+	}
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// sha256State is an implementation of SHA-256 that keeps all of its state inside caller provided memory. Only lengths, which are not secret, are held elsewhere.
+type sha256State struct {
+	h   []byte // chaining value, 8 words
+	w   []byte // message schedule, 64 words
+	buf []byte // pending partial block
+	n   int    // number of bytes in buf
+	len uint64 // total number of bytes written
+}
+
+const (
+	sha256Size       = 32
+	sha256BlockSize  = 64
+	sha256ScratchLen = 32 + 256 + sha256BlockSize
+)
+
+var sha256IV = [8]uint32{
+	0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19,
+}
+
+var sha256K = [64]uint32{
+	0x428a2f98, 0x71374491, 0xb5c0fbcf, 0xe9b5dba5, 0x3956c25b, 0x59f111f1, 0x923f82a4, 0xab1c5ed5,
+	0xd807aa98, 0x12835b01, 0x243185be, 0x550c7dc3, 0x72be5d74, 0x80deb1fe, 0x9bdc06a7, 0xc19bf174,
+	0xe49b69c1, 0xefbe4786, 0x0fc19dc6, 0x240ca1cc, 0x2de92c6f, 0x4a7484aa, 0x5cb0a9dc, 0x76f988da,
+	0x983e5152, 0xa831c66d, 0xb00327c8, 0xbf597fc7, 0xc6e00bf3, 0xd5a79147, 0x06ca6351, 0x14292967,
+	0x27b70a85, 0x2e1b2138, 0x4d2c6dfc, 0x53380d13, 0x650a7354, 0x766a0abb, 0x81c2c92e, 0x92722c85,
+	0xa2bfe8a1, 0xa81a664b, 0xc24b8b70, 0xc76c51a3, 0xd192e819, 0xd6990624, 0xf40e3585, 0x106aa070,
+	0x19a4c116, 0x1e376c08, 0x2748774c, 0x34b0bcb5, 0x391c0cb3, 0x4ed8aa4a, 0x5b9cca4f, 0x682e6ff3,
+	0x748f82ee, 0x78a5636f, 0x84c87814, 0x8cc70208, 0x90befffa, 0xa4506ceb, 0xbef9a3f7, 0xc67178f2,
+}
+
+// Initialises a SHA-256 state inside the given scratch space, which must be at least sha256ScratchLen bytes long.
+func newSHA256(scratch []byte) *sha256State {
+	s := &sha256State{
+		h:   scratch[0:32],
+		w:   scratch[32:288],
+		buf: scratch[288:sha256ScratchLen],
+	}
+	for i, v := range sha256IV {
+		binary.BigEndian.PutUint32(s.h[4*i:], v)
+	}
+	return s
+}
+
+func (s *sha256State) write(p []byte) {
+	s.len += uint64(len(p))
+	if s.n > 0 {
+		c := copy(s.buf[s.n:], p)
+		s.n += c
+		p = p[c:]
+		if s.n < sha256BlockSize {
+			return
+		}
+		s.block(s.buf)
+		s.n = 0
+	}
+	for len(p) >= sha256BlockSize {
+		s.block(p[:sha256BlockSize])
+		p = p[sha256BlockSize:]
+	}
+	s.n = copy(s.buf, p)
+}
+
+// Writes the digest into the first sha256Size bytes of out. The state must not be used afterwards.
+func (s *sha256State) sum(out []byte) {
+	length := s.len << 3
+
+	// Pad with a one bit, zeroes, and the message length in bits.
+	s.buf[s.n] = 0x80
+	Wipe(s.buf[s.n+1:])
+	if s.n+1 > sha256BlockSize-8 {
+		s.block(s.buf)
+		Wipe(s.buf)
+	}
+	binary.BigEndian.PutUint64(s.buf[sha256BlockSize-8:], length)
+	s.block(s.buf)
+
+	copy(out[:sha256Size], s.h)
+}
+
+func (s *sha256State) block(p []byte) {
+	w := s.w
+	for i := 0; i < 16; i++ {
+		binary.BigEndian.PutUint32(w[4*i:], binary.BigEndian.Uint32(p[4*i:]))
+	}
+	for i := 16; i < 64; i++ {
+		v1 := binary.BigEndian.Uint32(w[4*(i-2):])
+		t1 := bits.RotateLeft32(v1, -17) ^ bits.RotateLeft32(v1, -19) ^ (v1 >> 10)
+		v2 := binary.BigEndian.Uint32(w[4*(i-15):])
+		t2 := bits.RotateLeft32(v2, -7) ^ bits.RotateLeft32(v2, -18) ^ (v2 >> 3)
+		binary.BigEndian.PutUint32(w[4*i:], t1+binary.BigEndian.Uint32(w[4*(i-7):])+t2+binary.BigEndian.Uint32(w[4*(i-16):]))
+	}
+
+	a := binary.BigEndian.Uint32(s.h[0:])
+	b := binary.BigEndian.Uint32(s.h[4:])
+	c := binary.BigEndian.Uint32(s.h[8:])
+	d := binary.BigEndian.Uint32(s.h[12:])
+	e := binary.BigEndian.Uint32(s.h[16:])
+	f := binary.BigEndian.Uint32(s.h[20:])
+	g := binary.BigEndian.Uint32(s.h[24:])
+	h := binary.BigEndian.Uint32(s.h[28:])
+
+	for i := 0; i < 64; i++ {
+		t1 := h + (bits.RotateLeft32(e, -6) ^ bits.RotateLeft32(e, -11) ^ bits.RotateLeft32(e, -25)) + ((e & f) ^ (^e & g)) + sha256K[i] + binary.BigEndian.Uint32(w[4*i:])
+		t2 := (bits.RotateLeft32(a, -2) ^ bits.RotateLeft32(a, -13) ^ bits.RotateLeft32(a, -22)) + ((a & b) ^ (a & c) ^ (b & c))
+		h, g, f, e, d, c, b, a = g, f, e, d+t1, c, b, a, t1+t2
+	}
+
+	for i, v := range [8]uint32{a, b, c, d, e, f, g, h} {
+		binary.BigEndian.PutUint32(s.h[4*i:], binary.BigEndian.Uint32(s.h[4*i:])+v)
+	}
+}
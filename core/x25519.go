@@ -0,0 +1,94 @@
+package core
+
+import (
+	"errors"
+)
+
+// X25519ScratchSize is the number of 64 bit words of scratch space needed by X25519. Every intermediate value derived from the scalar is kept in this space, so it should be allocated within a Buffer.
+const X25519ScratchSize = 5 * feCount
+
+// ErrInvalidPoint is returned by X25519 when the point is not 32 bytes long or is of low order, such that the result would be all zeroes.
+var ErrInvalidPoint = errors.New("<memguard::core::ErrInvalidPoint> point must be 32 bytes long and not of low order")
+
+// Number of field elements needed by the ladder.
+const feCount = 15
+
+/*
+X25519 performs the X25519 function from RFC 7748 on a 32 byte scalar and a 32 byte point, writing the 32 byte result into dst. The scalar is clamped as it is read and is not modified.
+
+The scratch slice must be at least X25519ScratchSize words long and holds all of the intermediate field elements. It is not wiped by this function. The computation runs in constant time with respect to the scalar.
+*/
+func X25519(dst, scalar, point []byte, scratch []uint64) error {
+	if len(scalar) != 32 {
+		return ErrInvalidKeyLength
+	}
+	if len(point) != 32 {
+		return ErrInvalidPoint
+	}
+
+	var t [feCount]fe
+	for i := range t {
+		t[i] = fe(scratch[5*i : 5*i+5 : 5*i+5])
+	}
+	x1, x2, z2, x3, z3 := t[0], t[1], t[2], t[3], t[4]
+	a, aa, b, bb, e, c, d, da, cb, tmp := t[5], t[6], t[7], t[8], t[9], t[10], t[11], t[12], t[13], t[14]
+
+	feFromBytes(x1, point)
+	feOne(x2)
+	feZero(z2)
+	copy(x3, x1)
+	feOne(z3)
+
+	// The Montgomery ladder from RFC 7748, section 5.
+	var swap uint64
+	for pos := 254; pos >= 0; pos-- {
+		bit := uint64(scalar[pos/8]>>(pos&7)) & 1
+		switch pos {
+		case 254:
+			bit = 1
+		case 2, 1, 0:
+			bit = 0
+		}
+		swap ^= bit
+		feCSwap(x2, x3, swap)
+		feCSwap(z2, z3, swap)
+		swap = bit
+
+		feAdd(a, x2, z2)
+		feMul(aa, a, a)
+		feSub(b, x2, z2)
+		feMul(bb, b, b)
+		feSub(e, aa, bb)
+		feAdd(c, x3, z3)
+		feSub(d, x3, z3)
+		feMul(da, d, a)
+		feMul(cb, c, b)
+
+		feAdd(x3, da, cb)
+		feMul(x3, x3, x3)
+		feSub(z3, da, cb)
+		feMul(z3, z3, z3)
+		feMul(z3, z3, x1)
+		feMul(x2, aa, bb)
+		feMul121665(tmp, e)
+		feAdd(tmp, tmp, aa)
+		feMul(z2, e, tmp)
+	}
+	feCSwap(x2, x3, swap)
+	feCSwap(z2, z3, swap)
+
+	// x2 / z2
+	feInvert(tmp, z2, a)
+	feMul(x2, x2, tmp)
+	feToBytes(dst, x2)
+
+	// Reject low order points, which would give an all zero result.
+	var acc byte
+	for _, v := range dst[:32] {
+		acc |= v
+	}
+	if acc == 0 {
+		return ErrInvalidPoint
+	}
+	return nil
+}
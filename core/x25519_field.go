@@ -0,0 +1,236 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//    * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//    * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//    * Neither the name of Google LLC nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package core
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+/*
+The field arithmetic in this file is adapted from the generic implementation in the Go standard library's crypto/internal/edwards25519/field package (fe.go and fe_generic.go), which is distributed under the BSD license reproduced above. The functions have been changed to operate on limbs held in caller provided scratch space, so that no intermediate values are left on the stack or heap, and the operations not needed by X25519 have been left out.
+*/
+
+// fe is an element of GF(2^255-19) represented as five 51 bit limbs in little-endian order. The limbs always point into caller provided scratch space.
+type fe []uint64
+
+const maskLow51 = (1 << 51) - 1
+
+func feZero(v fe) {
+	v[0], v[1], v[2], v[3], v[4] = 0, 0, 0, 0, 0
+}
+
+func feOne(v fe) {
+	v[0], v[1], v[2], v[3], v[4] = 1, 0, 0, 0, 0
+}
+
+// Decodes a little-endian point, ignoring the most significant bit.
+func feFromBytes(v fe, b []byte) {
+	v[0] = binary.LittleEndian.Uint64(b[0:8]) & maskLow51
+	v[1] = (binary.LittleEndian.Uint64(b[6:14]) >> 3) & maskLow51
+	v[2] = (binary.LittleEndian.Uint64(b[12:20]) >> 6) & maskLow51
+	v[3] = (binary.LittleEndian.Uint64(b[19:27]) >> 1) & maskLow51
+	v[4] = (binary.LittleEndian.Uint64(b[24:32]) >> 12) & maskLow51
+}
+
+// Reduces v to its canonical form in place and encodes it into out in little-endian order.
+func feToBytes(out []byte, v fe) {
+	feCarry(v)
+
+	// v is now below 2^255 + 2^13 * 19. Subtract p if v >= p.
+	c := (v[0] + 19) >> 51
+	c = (v[1] + c) >> 51
+	c = (v[2] + c) >> 51
+	c = (v[3] + c) >> 51
+	c = (v[4] + c) >> 51
+
+	v[0] += 19 * c
+	v[1] += v[0] >> 51
+	v[0] &= maskLow51
+	v[2] += v[1] >> 51
+	v[1] &= maskLow51
+	v[3] += v[2] >> 51
+	v[2] &= maskLow51
+	v[4] += v[3] >> 51
+	v[3] &= maskLow51
+	v[4] &= maskLow51
+
+	binary.LittleEndian.PutUint64(out[0:8], v[0]|v[1]<<51)
+	binary.LittleEndian.PutUint64(out[8:16], v[1]>>13|v[2]<<38)
+	binary.LittleEndian.PutUint64(out[16:24], v[2]>>26|v[3]<<25)
+	binary.LittleEndian.PutUint64(out[24:32], v[3]>>39|v[4]<<12)
+}
+
+// Brings the limbs of v back below 2^51 + 2^13 * 19.
+func feCarry(v fe) {
+	c0 := v[0] >> 51
+	c1 := v[1] >> 51
+	c2 := v[2] >> 51
+	c3 := v[3] >> 51
+	c4 := v[4] >> 51
+
+	v[0] = v[0]&maskLow51 + c4*19
+	v[1] = v[1]&maskLow51 + c0
+	v[2] = v[2]&maskLow51 + c1
+	v[3] = v[3]&maskLow51 + c2
+	v[4] = v[4]&maskLow51 + c3
+}
+
+func feAdd(out, a, b fe) {
+	for i := 0; i < 5; i++ {
+		out[i] = a[i] + b[i]
+	}
+	feCarry(out)
+}
+
+func feSub(out, a, b fe) {
+	// Add 2p first so that the limbs do not underflow.
+	out[0] = (a[0] + 0xFFFFFFFFFFFDA) - b[0]
+	out[1] = (a[1] + 0xFFFFFFFFFFFFE) - b[1]
+	out[2] = (a[2] + 0xFFFFFFFFFFFFE) - b[2]
+	out[3] = (a[3] + 0xFFFFFFFFFFFFE) - b[3]
+	out[4] = (a[4] + 0xFFFFFFFFFFFFE) - b[4]
+	feCarry(out)
+}
+
+// Swaps a and b if swap is 1, and leaves them unchanged if swap is 0, in constant time.
+func feCSwap(a, b fe, swap uint64) {
+	mask := -swap
+	for i := 0; i < 5; i++ {
+		t := mask & (a[i] ^ b[i])
+		a[i] ^= t
+		b[i] ^= t
+	}
+}
+
+// uint128 holds the 128 bit intermediate products of feMul.
+type uint128 struct {
+	lo, hi uint64
+}
+
+func mul64(a, b uint64) uint128 {
+	hi, lo := bits.Mul64(a, b)
+	return uint128{lo, hi}
+}
+
+func addMul64(v uint128, a, b uint64) uint128 {
+	hi, lo := bits.Mul64(a, b)
+	lo, carry := bits.Add64(lo, v.lo, 0)
+	hi, _ = bits.Add64(hi, v.hi, carry)
+	return uint128{lo, hi}
+}
+
+func shiftRightBy51(a uint128) uint64 {
+	return (a.hi << (64 - 51)) | (a.lo >> 51)
+}
+
+// Sets out = a * b. The inputs are read in full before out is written, so they may alias it.
+func feMul(out, a, b fe) {
+	a0, a1, a2, a3, a4 := a[0], a[1], a[2], a[3], a[4]
+	b0, b1, b2, b3, b4 := b[0], b[1], b[2], b[3], b[4]
+
+	// Reduction modulo 2^255-19 folds the high limbs back in multiplied by 19.
+	b1_19 := b1 * 19
+	b2_19 := b2 * 19
+	b3_19 := b3 * 19
+	b4_19 := b4 * 19
+
+	r0 := mul64(a0, b0)
+	r0 = addMul64(r0, a1, b4_19)
+	r0 = addMul64(r0, a2, b3_19)
+	r0 = addMul64(r0, a3, b2_19)
+	r0 = addMul64(r0, a4, b1_19)
+
+	r1 := mul64(a0, b1)
+	r1 = addMul64(r1, a1, b0)
+	r1 = addMul64(r1, a2, b4_19)
+	r1 = addMul64(r1, a3, b3_19)
+	r1 = addMul64(r1, a4, b2_19)
+
+	r2 := mul64(a0, b2)
+	r2 = addMul64(r2, a1, b1)
+	r2 = addMul64(r2, a2, b0)
+	r2 = addMul64(r2, a3, b4_19)
+	r2 = addMul64(r2, a4, b3_19)
+
+	r3 := mul64(a0, b3)
+	r3 = addMul64(r3, a1, b2)
+	r3 = addMul64(r3, a2, b1)
+	r3 = addMul64(r3, a3, b0)
+	r3 = addMul64(r3, a4, b4_19)
+
+	r4 := mul64(a0, b4)
+	r4 = addMul64(r4, a1, b3)
+	r4 = addMul64(r4, a2, b2)
+	r4 = addMul64(r4, a3, b1)
+	r4 = addMul64(r4, a4, b0)
+
+	c0 := shiftRightBy51(r0)
+	c1 := shiftRightBy51(r1)
+	c2 := shiftRightBy51(r2)
+	c3 := shiftRightBy51(r3)
+	c4 := shiftRightBy51(r4)
+
+	out[0] = r0.lo&maskLow51 + c4*19
+	out[1] = r1.lo&maskLow51 + c0
+	out[2] = r2.lo&maskLow51 + c1
+	out[3] = r3.lo&maskLow51 + c2
+	out[4] = r4.lo&maskLow51 + c3
+	feCarry(out)
+}
+
+// Sets out = a * 121665, the constant (A - 2) / 4 for Curve25519.
+func feMul121665(out, a fe) {
+	r0 := mul64(a[0], 121665)
+	r1 := mul64(a[1], 121665)
+	r2 := mul64(a[2], 121665)
+	r3 := mul64(a[3], 121665)
+	r4 := mul64(a[4], 121665)
+
+	out[0] = r0.lo&maskLow51 + shiftRightBy51(r4)*19
+	out[1] = r1.lo&maskLow51 + shiftRightBy51(r0)
+	out[2] = r2.lo&maskLow51 + shiftRightBy51(r1)
+	out[3] = r3.lo&maskLow51 + shiftRightBy51(r2)
+	out[4] = r4.lo&maskLow51 + shiftRightBy51(r3)
+	feCarry(out)
+}
+
+// Sets out = z^(p-2) = 1/z, using t as temporary space. The exponent is public, so the sequence of operations does not depend on any secret.
+func feInvert(out, z, t fe) {
+	copy(t, z)
+	feOne(out)
+
+	// p - 2 = 2^255 - 21, processed from the most significant bit.
+	for pos := 254; pos >= 0; pos-- {
+		feMul(out, out, out)
+		if pos >= 5 || (0b01011>>pos)&1 == 1 {
+			feMul(out, out, t)
+		}
+	}
+}
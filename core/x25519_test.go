@@ -0,0 +1,83 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+func TestX25519(t *testing.T) {
+	scratch := make([]uint64, X25519ScratchSize)
+	out := make([]byte, 32)
+
+	// RFC 7748, section 5.2.
+	scalar, _ := hex.DecodeString("a546e36bf0527c9d3b16154b82465edd62144c0ac1fc5a18506a2244ba449ac4")
+	point, _ := hex.DecodeString("e6db6867583030db3594c1a424b15f7c726624ec26b3353b10a903a6d0ab1c4c")
+	want, _ := hex.DecodeString("c3da55379de9c6908e94ea4df28d084f32eccf03491c71f754b4075577a28552")
+	if err := X25519(out, scalar, point, scratch); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, want) {
+		t.Error("result does not match test vector")
+	}
+
+	// Random inputs against the reference implementation.
+	for i := 0; i < 100; i++ {
+		scalar := make([]byte, 32)
+		point := make([]byte, 32)
+		Scramble(scalar)
+		Scramble(point)
+
+		want, err := curve25519.X25519(scalar, point)
+		if err != nil {
+			continue // low order point
+		}
+		if err := X25519(out, scalar, point, scratch); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, want) {
+			t.Error("result does not match reference implementation")
+		}
+
+		// Base point multiplication.
+		want, _ = curve25519.X25519(scalar, curve25519.Basepoint)
+		if err := X25519(out, scalar, curve25519.Basepoint, scratch); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, want) {
+			t.Error("public key does not match reference implementation")
+		}
+	}
+
+	// Non-canonical point encodings are accepted and reduced.
+	point = bytes.Repeat([]byte{0xff}, 32)
+	want, _ = curve25519.X25519(scalar, point)
+	if err := X25519(out, scalar, point, scratch); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, want) {
+		t.Error("result does not match reference implementation for non-canonical point")
+	}
+}
+
+func TestX25519Errors(t *testing.T) {
+	scratch := make([]uint64, X25519ScratchSize)
+	out := make([]byte, 32)
+	scalar := make([]byte, 32)
+	Scramble(scalar)
+
+	// Low order points.
+	for _, p := range [][]byte{make([]byte, 32), append([]byte{1}, make([]byte, 31)...)} {
+		if err := X25519(out, scalar, p, scratch); err != ErrInvalidPoint {
+			t.Error("expected ErrInvalidPoint; got", err)
+		}
+	}
+	if err := X25519(out, scalar, make([]byte, 31), scratch); err != ErrInvalidPoint {
+		t.Error("expected ErrInvalidPoint; got", err)
+	}
+	if err := X25519(out, scalar[:16], curve25519.Basepoint, scratch); err != ErrInvalidKeyLength {
+		t.Error("expected ErrInvalidKeyLength; got", err)
+	}
+}
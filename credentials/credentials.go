@@ -0,0 +1,122 @@
+/*
+Package credentials loads secrets passed to a service by systemd using the LoadCredential=, LoadCredentialEncrypted=, SetCredential= and SetCredentialEncrypted= directives.
+
+systemd decrypts each credential and places it in its own file inside the directory named by the CREDENTIALS_DIRECTORY environment variable, using the credential's name as the file name. The functions in this package read those files directly into locked memory.
+*/
+package credentials
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/awnumar/memguard"
+)
+
+// EnvDirectory is the environment variable systemd uses to tell a service where its credentials are.
+const EnvDirectory = "CREDENTIALS_DIRECTORY"
+
+// ErrNoDirectory is returned when the service was not passed any credentials.
+var ErrNoDirectory = errors.New("<memguard::credentials::ErrNoDirectory> " + EnvDirectory + " is not set")
+
+// ErrInvalidName is returned when a credential name is empty or would refer to a file outside of the credentials directory.
+var ErrInvalidName = errors.New("<memguard::credentials::ErrInvalidName> invalid credential name")
+
+/*
+Directory returns the path of the directory containing the credentials of the service.
+*/
+func Directory() (string, error) {
+	dir := os.Getenv(EnvDirectory)
+	if dir == "" {
+		return "", ErrNoDirectory
+	}
+	return dir, nil
+}
+
+/*
+List returns the names of all of the credentials available to the service, in lexical order.
+*/
+func List() ([]string, error) {
+	dir, err := Directory()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+/*
+OpenBuffer reads the credential with the given name into an immutable LockedBuffer. An empty credential results in a destroyed LockedBuffer being returned.
+
+systemd locks down the credentials directory itself, and grants the service's user access to the files inside it through a POSIX ACL when the service does not run as root. This makes the files appear to be readable by their group, so only files that are readable by everyone are refused, with memguard.ErrInsecurePermissions, since systemd never makes a credential readable by everyone.
+*/
+func OpenBuffer(name string) (*memguard.LockedBuffer, error) {
+	path, err := credentialPath(name)
+	if err != nil {
+		return memguard.NewBuffer(0), err
+	}
+
+	b, err := memguard.NewBufferFromFile(path, memguard.FileOptions{InsecurePermissions: 0004})
+	if err != nil {
+		b.Destroy()
+		return memguard.NewBuffer(0), err
+	}
+	return b, nil
+}
+
+/*
+Open reads the credential with the given name and returns it sealed inside an Enclave. An empty credential results in a nil Enclave being returned.
+*/
+func Open(name string) (*memguard.Enclave, error) {
+	b, err := OpenBuffer(name)
+	if err != nil {
+		return nil, err
+	}
+	return b.Seal(), nil
+}
+
+/*
+OpenAll reads every credential available to the service and returns them sealed inside Enclaves, keyed by name. Empty credentials are omitted.
+*/
+func OpenAll() (map[string]*memguard.Enclave, error) {
+	names, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	creds := make(map[string]*memguard.Enclave, len(names))
+	for _, name := range names {
+		e, err := Open(name)
+		if err != nil {
+			return nil, err
+		}
+		if e != nil {
+			creds[name] = e
+		}
+	}
+	return creds, nil
+}
+
+// Returns the path to the file holding a credential.
+func credentialPath(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+		return "", ErrInvalidName
+	}
+
+	dir, err := Directory()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
@@ -0,0 +1,155 @@
+package credentials
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/awnumar/memguard"
+)
+
+// Populates a temporary directory standing in for the one systemd would create.
+func setup(t *testing.T, creds map[string]string) string {
+	dir := t.TempDir()
+	for name, value := range creds {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0400); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "subdir"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvDirectory, dir)
+	return dir
+}
+
+func TestDirectory(t *testing.T) {
+	t.Setenv(EnvDirectory, "")
+	if _, err := Directory(); err != ErrNoDirectory {
+		t.Error("expected ErrNoDirectory; got", err)
+	}
+	if _, err := List(); err != ErrNoDirectory {
+		t.Error("expected ErrNoDirectory; got", err)
+	}
+	if _, err := Open("db_password"); err != ErrNoDirectory {
+		t.Error("expected ErrNoDirectory; got", err)
+	}
+
+	dir := setup(t, nil)
+	got, err := Directory()
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if got != dir {
+		t.Error("expected", dir, "got", got)
+	}
+}
+
+func TestList(t *testing.T) {
+	setup(t, map[string]string{"tls_key": "b", "db_password": "a"})
+	names, err := List()
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !reflect.DeepEqual(names, []string{"db_password", "tls_key"}) {
+		t.Error("unexpected names:", names)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	setup(t, map[string]string{"db_password": "yellow submarine", "empty": ""})
+
+	b, err := OpenBuffer("db_password")
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !b.EqualTo([]byte("yellow submarine")) {
+		t.Error("data does not match")
+	}
+	b.Destroy()
+
+	e, err := Open("db_password")
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	b, err = e.Open()
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !b.EqualTo([]byte("yellow submarine")) {
+		t.Error("data does not match")
+	}
+	b.Destroy()
+
+	e, err = Open("empty")
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if e != nil {
+		t.Error("expected nil enclave for empty credential")
+	}
+
+	if _, err := Open("missing"); !os.IsNotExist(err) {
+		t.Error("expected not exist error; got", err)
+	}
+
+	for _, name := range []string{"", ".", "..", "../db_password", "subdir/x", "/etc/passwd"} {
+		b, err := OpenBuffer(name)
+		if err != ErrInvalidName {
+			t.Errorf("%q: expected ErrInvalidName; got %v", name, err)
+		}
+		if b.IsAlive() {
+			t.Error("buffer should be destroyed")
+		}
+	}
+}
+
+func TestOpenInsecure(t *testing.T) {
+	dir := setup(t, map[string]string{"db_password": "yellow submarine"})
+	if err := os.Chmod(filepath.Join(dir, "db_password"), 0444); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open("db_password"); err != memguard.ErrInsecurePermissions {
+		t.Error("expected ErrInsecurePermissions; got", err)
+	}
+}
+
+func TestOpenGroupReadable(t *testing.T) {
+	// Access granted through an ACL shows up in the group permission bits.
+	dir := setup(t, map[string]string{"db_password": "yellow submarine"})
+	if err := os.Chmod(filepath.Join(dir, "db_password"), 0440); err != nil {
+		t.Fatal(err)
+	}
+	b, err := OpenBuffer("db_password")
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !b.EqualTo([]byte("yellow submarine")) {
+		t.Error("data does not match")
+	}
+	b.Destroy()
+}
+
+func TestOpenAll(t *testing.T) {
+	setup(t, map[string]string{"db_password": "yellow submarine", "api_token": "hunter2", "empty": ""})
+
+	creds, err := OpenAll()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(creds) != 2 {
+		t.Error("expected two credentials; got", len(creds))
+	}
+	for name, want := range map[string]string{"db_password": "yellow submarine", "api_token": "hunter2"} {
+		b, err := creds[name].Open()
+		if err != nil {
+			t.Error("unexpected error:", err)
+			continue
+		}
+		if !b.EqualTo([]byte(want)) {
+			t.Error("data does not match for", name)
+		}
+		b.Destroy()
+	}
+}
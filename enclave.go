@@ -57,3 +57,22 @@ Size returns the number of bytes of data stored within an Enclave.
 func (e *Enclave) Size() int {
 	return core.EnclaveSize(e.Enclave)
 }
+
+/*
+Equal decrypts two Enclave objects and performs a time-constant comparison on their contents. The comparison does not terminate early if the sizes of the enclaves differ. An error will be returned if either decryption failed.
+*/
+func (e *Enclave) Equal(other *Enclave) (bool, error) {
+	x, err := e.Open()
+	if err != nil {
+		return false, err
+	}
+	defer x.Destroy()
+
+	y, err := other.Open()
+	if err != nil {
+		return false, err
+	}
+	defer y.Destroy()
+
+	return x.Equal(y), nil
+}
@@ -82,6 +82,34 @@ func TestOpen(t *testing.T) {
 	}
 }
 
+func TestEnclaveEqual(t *testing.T) {
+	a := NewEnclave([]byte("yellow submarine"))
+	b := NewEnclave([]byte("yellow submarine"))
+	c := NewEnclave([]byte("yellow"))
+	eq, err := a.Equal(b)
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !eq {
+		t.Error("comparison incorrect")
+	}
+	eq, err = a.Equal(c)
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if eq {
+		t.Error("comparison incorrect with differing lengths")
+	}
+	Purge() // reset the session
+	eq, err = a.Equal(b)
+	if err != core.ErrDecryptionFailed {
+		t.Error("expected decryption error; got", err)
+	}
+	if eq {
+		t.Error("comparison should fail")
+	}
+}
+
 func panics(fn func()) (panicked bool) {
 	defer func() {
 		panicked = (recover() != nil)
@@ -0,0 +1,106 @@
+package memguard
+
+import (
+	"github.com/awnumar/memguard/core"
+)
+
+/*
+NewBufferFromHex decodes hex encoded data from a byte slice into an immutable LockedBuffer. Both upper and lower case characters are accepted. The source buffer is wiped after it has been decoded, regardless of whether the decoding succeeded.
+
+The decoding is performed in constant time and the plaintext is written directly into locked memory. If the input is not valid hex, core.ErrInvalidEncoding is returned.
+*/
+func NewBufferFromHex(src []byte) (*LockedBuffer, error) {
+	return newBufferFromEncoded(src, core.HexDecodedLen(len(src)), core.HexDecode)
+}
+
+/*
+NewBufferFromBase64 decodes padded standard base64 encoded data from a byte slice into an immutable LockedBuffer. The source buffer is wiped after it has been decoded, regardless of whether the decoding succeeded.
+
+The decoding is performed in constant time and the plaintext is written directly into locked memory. If the input is not valid base64, core.ErrInvalidEncoding is returned.
+*/
+func NewBufferFromBase64(src []byte) (*LockedBuffer, error) {
+	return newBufferFromEncoded(src, core.Base64DecodedLen(len(src)), core.Base64Decode)
+}
+
+/*
+NewBufferFromBase32 decodes padded standard base32 encoded data from a byte slice into an immutable LockedBuffer. The source buffer is wiped after it has been decoded, regardless of whether the decoding succeeded.
+
+The decoding is performed in constant time and the plaintext is written directly into locked memory. If the input is not valid base32, core.ErrInvalidEncoding is returned.
+*/
+func NewBufferFromBase32(src []byte) (*LockedBuffer, error) {
+	return newBufferFromEncoded(src, core.Base32DecodedLen(len(src)), core.Base32Decode)
+}
+
+// Decodes src into a LockedBuffer of at most size bytes, shrinking it if padding meant that less data was decoded.
+func newBufferFromEncoded(src []byte, size int, decode func(dst, src []byte) (int, error)) (*LockedBuffer, error) {
+	// Wipe the source once we are done with it.
+	defer core.Wipe(src)
+
+	// Construct a buffer large enough to hold the decoded data.
+	b := NewBuffer(size)
+
+	// Decode directly into the guarded allocation.
+	n, err := decode(b.Bytes(), src)
+	if err != nil {
+		b.Destroy()
+		return newNullBuffer(), err
+	}
+	if n == 0 {
+		// no data
+		b.Destroy()
+		return newNullBuffer(), nil
+	}
+
+	// The encoding was padded so we have allocated too much space.
+	if n < b.Size() {
+		d := NewBuffer(n)
+		d.Copy(b.Bytes()[:n])
+		b.Destroy()
+		b = d
+	}
+
+	b.Freeze()
+	return b, nil
+}
+
+/*
+EncodeHex returns the lowercase hex encoding of the contents of a LockedBuffer inside a new immutable LockedBuffer. The encoding is performed in constant time.
+
+If called on a destroyed LockedBuffer, a destroyed LockedBuffer is returned.
+*/
+func (b *LockedBuffer) EncodeHex() *LockedBuffer {
+	return b.encode(core.HexEncodedLen, core.HexEncode)
+}
+
+/*
+EncodeBase64 returns the padded standard base64 encoding of the contents of a LockedBuffer inside a new immutable LockedBuffer. The encoding is performed in constant time.
+
+If called on a destroyed LockedBuffer, a destroyed LockedBuffer is returned.
+*/
+func (b *LockedBuffer) EncodeBase64() *LockedBuffer {
+	return b.encode(core.Base64EncodedLen, core.Base64Encode)
+}
+
+/*
+EncodeBase32 returns the padded standard base32 encoding of the contents of a LockedBuffer inside a new immutable LockedBuffer. The encoding is performed in constant time.
+
+If called on a destroyed LockedBuffer, a destroyed LockedBuffer is returned.
+*/
+func (b *LockedBuffer) EncodeBase32() *LockedBuffer {
+	return b.encode(core.Base32EncodedLen, core.Base32Encode)
+}
+
+// Encodes the contents of a LockedBuffer into a new immutable LockedBuffer.
+func (b *LockedBuffer) encode(size func(int) int, encode func(dst, src []byte)) *LockedBuffer {
+	if !b.IsAlive() {
+		return newNullBuffer()
+	}
+
+	b.RLock()
+	defer b.RUnlock()
+
+	d := NewBuffer(size(b.Size()))
+	encode(d.Bytes(), b.Bytes())
+	d.Freeze()
+	return d
+}
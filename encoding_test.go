@@ -0,0 +1,140 @@
+package memguard
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/awnumar/memguard/core"
+)
+
+func TestNewBufferFromHex(t *testing.T) {
+	src := []byte("79656c6c6f77207375626D6172696E65")
+	b, err := NewBufferFromHex(src)
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !b.EqualTo([]byte("yellow submarine")) {
+		t.Error("data does not match")
+	}
+	if !bytes.Equal(src, make([]byte, len(src))) {
+		t.Error("source buffer not wiped")
+	}
+	if b.IsMutable() {
+		t.Error("buffer should be immutable")
+	}
+	b.Destroy()
+
+	src = []byte("79656c6c6f7")
+	b, err = NewBufferFromHex(src)
+	if err != core.ErrInvalidEncoding {
+		t.Error("expected ErrInvalidEncoding; got", err)
+	}
+	if b.IsAlive() {
+		t.Error("buffer should be destroyed")
+	}
+	if !bytes.Equal(src, make([]byte, len(src))) {
+		t.Error("source buffer not wiped on error")
+	}
+
+	b, err = NewBufferFromHex([]byte{})
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if b.Size() != 0 {
+		t.Error("buffer should be size zero")
+	}
+}
+
+func TestNewBufferFromBase64(t *testing.T) {
+	for size := 1; size < 8; size++ {
+		ref := make([]byte, size)
+		ScrambleBytes(ref)
+		src := []byte(base64.StdEncoding.EncodeToString(ref))
+		b, err := NewBufferFromBase64(src)
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+		if b.Size() != size {
+			t.Error("unexpected size", b.Size(), "want", size)
+		}
+		if !b.EqualTo(ref) {
+			t.Error("data does not match")
+		}
+		if !bytes.Equal(src, make([]byte, len(src))) {
+			t.Error("source buffer not wiped")
+		}
+		b.Destroy()
+	}
+
+	b, err := NewBufferFromBase64([]byte("eWVsbG93-A=="))
+	if err != core.ErrInvalidEncoding {
+		t.Error("expected ErrInvalidEncoding; got", err)
+	}
+	if b.IsAlive() {
+		t.Error("buffer should be destroyed")
+	}
+}
+
+func TestNewBufferFromBase32(t *testing.T) {
+	for size := 1; size < 12; size++ {
+		ref := make([]byte, size)
+		ScrambleBytes(ref)
+		src := []byte(base32.StdEncoding.EncodeToString(ref))
+		b, err := NewBufferFromBase32(src)
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+		if b.Size() != size {
+			t.Error("unexpected size", b.Size(), "want", size)
+		}
+		if !b.EqualTo(ref) {
+			t.Error("data does not match")
+		}
+		if !bytes.Equal(src, make([]byte, len(src))) {
+			t.Error("source buffer not wiped")
+		}
+		b.Destroy()
+	}
+
+	b, err := NewBufferFromBase32([]byte("PFSWY3DP"[:7]))
+	if err != core.ErrInvalidEncoding {
+		t.Error("expected ErrInvalidEncoding; got", err)
+	}
+	if b.IsAlive() {
+		t.Error("buffer should be destroyed")
+	}
+}
+
+func TestEncode(t *testing.T) {
+	ref := []byte("yellow submarine!")
+	b := NewBufferFromBytes(append([]byte{}, ref...))
+
+	h := b.EncodeHex()
+	if !h.EqualTo([]byte(hex.EncodeToString(ref))) {
+		t.Error("hex encoding incorrect")
+	}
+	if h.IsMutable() {
+		t.Error("buffer should be immutable")
+	}
+	h.Destroy()
+
+	e := b.EncodeBase64()
+	if !e.EqualTo([]byte(base64.StdEncoding.EncodeToString(ref))) {
+		t.Error("base64 encoding incorrect")
+	}
+	e.Destroy()
+
+	e = b.EncodeBase32()
+	if !e.EqualTo([]byte(base32.StdEncoding.EncodeToString(ref))) {
+		t.Error("base32 encoding incorrect")
+	}
+	e.Destroy()
+
+	b.Destroy()
+	if b.EncodeHex().IsAlive() {
+		t.Error("encoding a destroyed buffer should return a destroyed buffer")
+	}
+}
@@ -0,0 +1,41 @@
+package memguard
+
+import (
+	"errors"
+	"os"
+	"unsafe"
+
+	"github.com/awnumar/memguard/core"
+)
+
+// ErrEnvNotFound is returned when attempting to read a secret from an environment variable that is not set.
+var ErrEnvNotFound = errors.New("<memguard::ErrEnvNotFound> environment variable is not set")
+
+/*
+NewBufferFromEnv copies the value of an environment variable into an immutable LockedBuffer. If the variable is not set, ErrEnvNotFound is returned. A variable that is set to an empty value results in a destroyed LockedBuffer being returned.
+
+If unset is true, the variable is removed from the environment of the process after it has been copied. On Linux the value is additionally overwritten inside the environment block that the process was started with, so that it no longer appears in /proc/self/environ. This is done on a best-effort basis. Note that the Go runtime keeps its own copy of the initial environment on the heap, which can be removed from the environment but not reliably wiped.
+*/
+func NewBufferFromEnv(name string, unset bool) (*LockedBuffer, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return newNullBuffer(), ErrEnvNotFound
+	}
+
+	// Copy the value over without making an intermediate copy of it.
+	b := NewBuffer(len(value))
+	if b.Size() != 0 {
+		core.Copy(b.Bytes(), unsafe.Slice(unsafe.StringData(value), len(value)))
+		b.Freeze()
+	}
+
+	if unset {
+		if err := os.Unsetenv(name); err != nil {
+			b.Destroy()
+			return newNullBuffer(), err
+		}
+		wipeInitialEnv(name)
+	}
+
+	return b, nil
+}
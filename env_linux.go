@@ -0,0 +1,71 @@
+package memguard
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Overwrites the value of every entry for name in the environment block that the process was started with. This is the memory exposed through /proc/self/environ.
+func wipeInitialEnv(name string) {
+	start, end, err := initialEnvBlock()
+	if err != nil || end <= start {
+		return
+	}
+
+	// Writing through /proc/self/mem lets us reach the block without constructing pointers to it.
+	mem, err := os.OpenFile("/proc/self/mem", os.O_RDWR, 0)
+	if err != nil {
+		return
+	}
+	defer mem.Close()
+
+	// The block holds other secrets too, so only ever read it into locked memory.
+	b := NewBuffer(int(end - start))
+	defer b.Destroy()
+	if _, err := mem.ReadAt(b.Bytes(), int64(start)); err != nil {
+		return
+	}
+
+	prefix := []byte(name + "=")
+	for off := 0; off < b.Size(); {
+		n := bytes.IndexByte(b.Bytes()[off:], 0)
+		if n == -1 {
+			n = b.Size() - off
+		}
+		if bytes.HasPrefix(b.Bytes()[off:off+n], prefix) {
+			mem.WriteAt(make([]byte, n-len(prefix)), int64(start)+int64(off+len(prefix)))
+		}
+		off += n + 1
+	}
+}
+
+// Returns the address range of the initial environment block, from fields 50 and 51 of /proc/self/stat.
+func initialEnvBlock() (uint64, uint64, error) {
+	stat, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// The command name may contain spaces so start after its closing parenthesis, at field 3.
+	i := bytes.LastIndexByte(stat, ')')
+	if i == -1 {
+		return 0, 0, errors.New("<memguard::env> malformed /proc/self/stat")
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 49 {
+		return 0, 0, errors.New("<memguard::env> kernel does not report the environment block")
+	}
+
+	start, err := strconv.ParseUint(fields[47], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := strconv.ParseUint(fields[48], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
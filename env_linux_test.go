@@ -0,0 +1,53 @@
+package memguard
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestNewBufferFromEnvWipesInitialEnv(t *testing.T) {
+	const name = "MEMGUARD_TEST_INITIAL_ENV"
+	const value = "yellow submarine"
+
+	// If we're within the testing subprocess, run test.
+	if os.Getenv("WITHIN_SUBPROCESS") == "1" {
+		environ, err := os.ReadFile("/proc/self/environ")
+		if err != nil {
+			t.Skip(err)
+		}
+		if !bytes.Contains(environ, []byte(name+"="+value)) {
+			t.Fatal("variable not found in the initial environment")
+		}
+
+		b, err := NewBufferFromEnv(name, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer b.Destroy()
+		if !b.EqualTo([]byte(value)) {
+			t.Error("data does not match")
+		}
+
+		environ, err = os.ReadFile("/proc/self/environ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(environ, []byte(value)) {
+			t.Error("value still present in /proc/self/environ")
+		}
+		if !bytes.Contains(environ, []byte("WITHIN_SUBPROCESS=1")) {
+			t.Error("other variables should be untouched")
+		}
+		return
+	}
+
+	// Construct the subprocess with the secret in its initial environment
+	cmd := exec.Command(os.Args[0], "-test.run=TestNewBufferFromEnvWipesInitialEnv")
+	cmd.Env = append(os.Environ(), "WITHIN_SUBPROCESS=1", name+"="+value)
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Error("subprocess failed:", err, "\n", string(out))
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+// +build !linux
+
+package memguard
+
+// The initial environment block cannot be located on this platform.
+func wipeInitialEnv(name string) {}
@@ -0,0 +1,55 @@
+package memguard
+
+import (
+	"os"
+	"testing"
+)
+
+func TestNewBufferFromEnv(t *testing.T) {
+	const name = "MEMGUARD_TEST_ENV"
+
+	os.Setenv(name, "yellow submarine")
+	b, err := NewBufferFromEnv(name, false)
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !b.EqualTo([]byte("yellow submarine")) {
+		t.Error("data does not match")
+	}
+	if b.IsMutable() {
+		t.Error("buffer should be immutable")
+	}
+	b.Destroy()
+	if os.Getenv(name) != "yellow submarine" {
+		t.Error("variable should not have been modified")
+	}
+
+	b, err = NewBufferFromEnv(name, true)
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !b.EqualTo([]byte("yellow submarine")) {
+		t.Error("data does not match")
+	}
+	b.Destroy()
+	if _, ok := os.LookupEnv(name); ok {
+		t.Error("variable should have been unset")
+	}
+
+	b, err = NewBufferFromEnv(name, true)
+	if err != ErrEnvNotFound {
+		t.Error("expected ErrEnvNotFound; got", err)
+	}
+	if b.IsAlive() {
+		t.Error("buffer should be destroyed")
+	}
+
+	os.Setenv(name, "")
+	b, err = NewBufferFromEnv(name, true)
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if b.Size() != 0 {
+		t.Error("buffer should be size zero")
+	}
+}
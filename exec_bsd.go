@@ -0,0 +1,14 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package memguard
+
+import (
+	"errors"
+	"os"
+)
+
+// Sealed memory files are not available on this platform, so pipes are always used instead.
+func sealedFile(name string, b *LockedBuffer) (*os.File, error) {
+	return nil, errors.New("<memguard::exec> sealed memory files are not supported on this platform")
+}
@@ -0,0 +1,35 @@
+package memguard
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Writes the contents of a LockedBuffer into an anonymous memory file and seals it so that it can no longer be modified.
+func sealedFile(name string, b *LockedBuffer) (*os.File, error) {
+	fd, err := unix.MemfdCreate("memguard:"+name, unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), "memguard:"+name)
+
+	if _, err := f.Write(b.Bytes()); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// The child shares our file offset, so rewind it.
+	if _, err := f.Seek(0, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	seals := unix.F_SEAL_WRITE | unix.F_SEAL_SHRINK | unix.F_SEAL_GROW | unix.F_SEAL_SEAL
+	if _, err := unix.FcntlInt(f.Fd(), unix.F_ADD_SEALS, seals); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
@@ -0,0 +1,147 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package memguard
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// EnvSecrets is the environment variable through which ExecWithSecret tells a child process which file descriptors hold its secrets.
+const EnvSecrets = "MEMGUARD_SECRETS"
+
+// ErrInvalidSecretName is returned when a secret name passed to ExecWithSecret cannot be encoded in the environment of the child.
+var ErrInvalidSecretName = errors.New("<memguard::ErrInvalidSecretName> secret names must be non-empty and must not contain ':' or ','")
+
+/*
+ExecWithSecret starts a command, passing it the contents of some Enclaves as additional open files instead of on the command line or in the environment.
+
+On Linux each secret is written into an anonymous memory file created with memfd_create, which is then sealed against further modification. Elsewhere, or if this fails, the secret is written into a pipe once the command has started. In either case the plaintext is only held inside LockedBuffers in the parent.
+
+The files are appended to cmd.ExtraFiles and the EnvSecrets variable is added to cmd.Env so that the child can locate them by name using SecretFD, and read them with ReceiveSecret. The caller is responsible for calling cmd.Wait.
+*/
+func ExecWithSecret(cmd *exec.Cmd, secrets map[string]*Enclave) error {
+	return execWithSecret(cmd, secrets, true)
+}
+
+// A secret waiting to be written into a pipe once the child has started.
+type pendingSecret struct {
+	w *os.File
+	b *LockedBuffer
+}
+
+func execWithSecret(cmd *exec.Cmd, secrets map[string]*Enclave, memfd bool) error {
+	// Assign file descriptors in a deterministic order.
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		if name == "" || strings.ContainsAny(name, ":,") {
+			return ErrInvalidSecretName
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		files   []*os.File // our copies of the files given to the child
+		pending []pendingSecret
+	)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	abort := func() {
+		for _, p := range pending {
+			p.b.Destroy()
+			p.w.Close()
+		}
+	}
+
+	entries := make([]string, 0, len(names))
+	for _, name := range names {
+		b, err := secrets[name].Open()
+		if err != nil {
+			abort()
+			return err
+		}
+
+		var f *os.File
+		if memfd {
+			f, err = sealedFile(name, b)
+		}
+		if f != nil && err == nil {
+			b.Destroy()
+		} else {
+			// Fall back to a pipe.
+			var w *os.File
+			f, w, err = os.Pipe()
+			if err != nil {
+				b.Destroy()
+				abort()
+				return err
+			}
+			pending = append(pending, pendingSecret{w, b})
+		}
+		files = append(files, f)
+
+		// Descriptors 0, 1 and 2 are taken by the standard streams.
+		entries = append(entries, name+":"+strconv.Itoa(3+len(cmd.ExtraFiles)))
+		cmd.ExtraFiles = append(cmd.ExtraFiles, f)
+	}
+
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, EnvSecrets+"="+strings.Join(entries, ","))
+
+	if err := cmd.Start(); err != nil {
+		abort()
+		return err
+	}
+
+	// Feed the pipes. A write fails once the child exits, so these cannot leak.
+	for _, p := range pending {
+		go func(p pendingSecret) {
+			defer p.w.Close()
+			defer p.b.Destroy()
+			p.w.Write(p.b.Bytes())
+		}(p)
+	}
+
+	return nil
+}
+
+/*
+SecretFD returns the file descriptor through which a secret with the given name was passed to the current process by ExecWithSecret.
+*/
+func SecretFD(name string) (int, bool) {
+	for _, entry := range strings.Split(os.Getenv(EnvSecrets), ",") {
+		i := strings.LastIndexByte(entry, ':')
+		if i == -1 || entry[:i] != name {
+			continue
+		}
+		fd, err := strconv.Atoi(entry[i+1:])
+		if err != nil {
+			return 0, false
+		}
+		return fd, true
+	}
+	return 0, false
+}
+
+/*
+ReceiveSecret reads a secret passed by ExecWithSecret from a file descriptor directly into an immutable LockedBuffer. The file descriptor is closed afterwards.
+
+If an error is encountered before all the data could be read, it is returned along with any data read up until that point.
+*/
+func ReceiveSecret(fd int) (*LockedBuffer, error) {
+	defer unix.Close(fd)
+	return NewBufferFromEntireReader(fdReader(fd))
+}
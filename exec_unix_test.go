@@ -0,0 +1,113 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package memguard
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestExecWithSecret(t *testing.T) {
+	large := bytes.Repeat([]byte("yellow submarine"), 16384) // larger than a pipe buffer
+
+	// If we're within the testing subprocess, receive the secrets.
+	if os.Getenv("WITHIN_SUBPROCESS") == "1" {
+		if strings.Contains(strings.Join(os.Environ(), "\n"), "yellow submarine") {
+			t.Error("secret leaked into the environment")
+		}
+
+		fd, ok := SecretFD("db_password")
+		if !ok {
+			t.Fatal("secret not found")
+		}
+		if os.Getenv("EXPECT_SEALED") == "1" {
+			if _, err := unix.Write(fd, []byte("x")); err != unix.EPERM {
+				t.Error("expected sealed memory file; got", err)
+			}
+		}
+		b, err := ReceiveSecret(fd)
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+		if !b.EqualTo([]byte("yellow submarine")) {
+			t.Error("data does not match")
+		}
+		b.Destroy()
+
+		fd, ok = SecretFD("large")
+		if !ok {
+			t.Fatal("secret not found")
+		}
+		b, err = ReceiveSecret(fd)
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+		if !b.EqualTo(large) {
+			t.Error("data does not match")
+		}
+		b.Destroy()
+
+		if _, ok := SecretFD("missing"); ok {
+			t.Error("found a secret that was not passed")
+		}
+		return
+	}
+
+	for _, memfd := range []bool{true, false} {
+		ref := make([]byte, len(large))
+		copy(ref, large)
+		secrets := map[string]*Enclave{
+			"db_password": NewEnclave([]byte("yellow submarine")),
+			"large":       NewEnclave(ref),
+		}
+
+		cmd := exec.Command(os.Args[0], "-test.run=TestExecWithSecret")
+		cmd.Env = append(os.Environ(), "WITHIN_SUBPROCESS=1")
+		if memfd && runtime.GOOS == "linux" {
+			cmd.Env = append(cmd.Env, "EXPECT_SEALED=1")
+		}
+		var out bytes.Buffer
+		cmd.Stdout = &out
+		cmd.Stderr = &out
+
+		if err := execWithSecret(cmd, secrets, memfd); err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.Wait(); err != nil {
+			t.Error("subprocess failed:", err, "\n", out.String())
+		}
+		for _, arg := range cmd.Args {
+			if strings.Contains(arg, "yellow submarine") {
+				t.Error("secret leaked into the arguments")
+			}
+		}
+	}
+}
+
+func TestExecWithSecretInvalidName(t *testing.T) {
+	for _, name := range []string{"", "a:b", "a,b"} {
+		cmd := exec.Command(os.Args[0])
+		err := ExecWithSecret(cmd, map[string]*Enclave{name: NewEnclave([]byte("x"))})
+		if err != ErrInvalidSecretName {
+			t.Errorf("%q: expected ErrInvalidSecretName; got %v", name, err)
+		}
+		if cmd.Process != nil {
+			t.Error("command should not have been started")
+		}
+	}
+}
+
+func TestExecWithSecretStartFailure(t *testing.T) {
+	cmd := exec.Command("/nonexistent/command")
+	err := ExecWithSecret(cmd, map[string]*Enclave{"db_password": NewEnclave([]byte("yellow submarine"))})
+	if err == nil {
+		t.Error("expected error starting command")
+	}
+}
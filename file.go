@@ -0,0 +1,146 @@
+package memguard
+
+import (
+	"errors"
+	"os"
+	"runtime"
+
+	"github.com/awnumar/memguard/core"
+)
+
+// ErrInsecurePermissions is returned when attempting to read a secret from a file that can be read by users other than its owner.
+var ErrInsecurePermissions = errors.New("<memguard::ErrInsecurePermissions> file is readable by group or other users")
+
+/*
+FileOptions configures how NewBufferFromFile reads a file. The zero value refuses insecure files and leaves the file and its contents untouched.
+*/
+type FileOptions struct {
+	// AllowInsecurePermissions disables the check that the file is not readable by group or other users. The check is never performed on Windows.
+	AllowInsecurePermissions bool
+
+	// InsecurePermissions selects the permission bits that cause the file to be refused. If zero, files readable by group or other users are refused, as if it were 0044.
+	InsecurePermissions os.FileMode
+
+	// TrimNewline removes a single trailing "\n" or "\r\n" from the data.
+	TrimNewline bool
+
+	// Shred overwrites the file with random bytes and then removes it once it has been read successfully. If the file is not writable by its owner, write permission is added first. This is not effective on filesystems that do not overwrite data in place, such as copy-on-write or journaling filesystems.
+	Shred bool
+}
+
+/*
+NewBufferFromFile reads the contents of a file into an immutable LockedBuffer. The data is read directly into locked memory, without passing through any intermediate buffers.
+
+Unless opts.AllowInsecurePermissions is set, ErrInsecurePermissions is returned if the file has any of the permission bits in opts.InsecurePermissions set, which by default means it is readable by group or other users. If an error is encountered while reading, it is returned along with any data read up until that point, and the file is not shredded. If the file cannot be shredded, the error is returned along with all of the data.
+*/
+func NewBufferFromFile(path string, opts FileOptions) (*LockedBuffer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return newNullBuffer(), err
+	}
+	defer f.Close()
+
+	// Check the permissions on the file we actually opened.
+	info, err := f.Stat()
+	if err != nil {
+		return newNullBuffer(), err
+	}
+	insecure := opts.InsecurePermissions
+	if insecure == 0 {
+		insecure = 0044
+	}
+	if !opts.AllowInsecurePermissions && runtime.GOOS != "windows" && info.Mode().Perm()&insecure != 0 {
+		return newNullBuffer(), ErrInsecurePermissions
+	}
+
+	// Read the file straight into a guarded allocation.
+	b, err := NewBufferFromEntireReader(f)
+	if err != nil {
+		return b, err
+	}
+
+	if opts.TrimNewline {
+		b = trimNewline(b)
+	}
+
+	if opts.Shred {
+		if err := shred(path, info); err != nil {
+			return b, err
+		}
+	}
+
+	return b, nil
+}
+
+// Overwrites and removes the file that was read, whose details are given by info.
+func shred(path string, info os.FileInfo) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if os.IsPermission(err) {
+		// Secrets are often read-only, so allow ourselves to write to it.
+		if err := os.Chmod(path, info.Mode().Perm()|0200); err != nil {
+			return err
+		}
+		f, err = os.OpenFile(path, os.O_WRONLY, 0)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Make sure the file was not replaced after we read it.
+	current, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(info, current) {
+		return errors.New("<memguard::shred> file was replaced while it was being read")
+	}
+
+	if err := shredFile(f, info.Size()); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Removes a trailing "\n" or "\r\n" from an immutable LockedBuffer, returning the result in a new one if anything was removed.
+func trimNewline(b *LockedBuffer) *LockedBuffer {
+	n := b.Size()
+	if n > 0 && b.Bytes()[n-1] == '\n' {
+		n--
+		if n > 0 && b.Bytes()[n-1] == '\r' {
+			n--
+		}
+	}
+	if n == b.Size() {
+		return b
+	}
+
+	if n == 0 {
+		// there's no data left to return
+		b.Destroy()
+		return newNullBuffer()
+	}
+	d := NewBuffer(n)
+	d.Copy(b.Bytes()[:n])
+	d.Freeze()
+	b.Destroy()
+	return d
+}
+
+// Overwrites the first size bytes of a file with random data and flushes it to disk.
+func shredFile(f *os.File, size int64) error {
+	chunk := make([]byte, os.Getpagesize())
+	for off := int64(0); off < size; off += int64(len(chunk)) {
+		if err := core.Scramble(chunk); err != nil {
+			return err
+		}
+		n := int64(len(chunk))
+		if size-off < n {
+			n = size - off
+		}
+		if _, err := f.WriteAt(chunk[:n], off); err != nil {
+			return err
+		}
+	}
+	return f.Sync()
+}
@@ -0,0 +1,156 @@
+package memguard
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestNewBufferFromFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret")
+
+	if err := os.WriteFile(path, []byte("yellow submarine\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := NewBufferFromFile(path, FileOptions{})
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !b.EqualTo([]byte("yellow submarine\n")) {
+		t.Error("data does not match")
+	}
+	if b.IsMutable() {
+		t.Error("buffer should be immutable")
+	}
+	b.Destroy()
+
+	b, err = NewBufferFromFile(path, FileOptions{TrimNewline: true})
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !b.EqualTo([]byte("yellow submarine")) {
+		t.Error("newline not trimmed")
+	}
+	if b.IsMutable() {
+		t.Error("buffer should be immutable")
+	}
+	b.Destroy()
+
+	// Windows line endings and lone newlines.
+	for input, want := range map[string]string{
+		"yellow submarine\r\n": "yellow submarine",
+		"yellow submarine\r":   "yellow submarine\r",
+		"yellow submarine\n\n": "yellow submarine\n",
+		"\n":                   "",
+	} {
+		if err := os.WriteFile(path, []byte(input), 0600); err != nil {
+			t.Fatal(err)
+		}
+		b, err = NewBufferFromFile(path, FileOptions{TrimNewline: true})
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+		if !b.EqualTo([]byte(want)) {
+			t.Errorf("%q: expected %q; got %q", input, want, b.Bytes())
+		}
+		b.Destroy()
+	}
+
+	// Missing file.
+	b, err = NewBufferFromFile(filepath.Join(dir, "missing"), FileOptions{})
+	if !os.IsNotExist(err) {
+		t.Error("expected not exist error; got", err)
+	}
+	if b.IsAlive() {
+		t.Error("buffer should be destroyed")
+	}
+}
+
+func TestNewBufferFromFilePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not checked on windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("yellow submarine"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []os.FileMode{0640, 0604, 0644} {
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+		b, err := NewBufferFromFile(path, FileOptions{})
+		if err != ErrInsecurePermissions {
+			t.Error("expected ErrInsecurePermissions for", mode, "got", err)
+		}
+		if b.IsAlive() {
+			t.Error("buffer should be destroyed")
+		}
+
+		b, err = NewBufferFromFile(path, FileOptions{AllowInsecurePermissions: true})
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+		if !b.EqualTo([]byte("yellow submarine")) {
+			t.Error("data does not match")
+		}
+		b.Destroy()
+
+		// Only the selected bits are checked.
+		b, err = NewBufferFromFile(path, FileOptions{InsecurePermissions: 0004})
+		if mode&0004 != 0 {
+			if err != ErrInsecurePermissions {
+				t.Error("expected ErrInsecurePermissions for", mode, "got", err)
+			}
+		} else if err != nil || !b.EqualTo([]byte("yellow submarine")) {
+			t.Error("unexpected result for", mode, err)
+		}
+		b.Destroy()
+	}
+}
+
+func TestNewBufferFromFileShred(t *testing.T) {
+	// Read-only files should be shredded too.
+	for _, mode := range []os.FileMode{0600, 0400} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "secret")
+		data := bytes.Repeat([]byte("yellow submarine"), 1000)
+		if err := os.WriteFile(path, data, mode); err != nil {
+			t.Fatal(err)
+		}
+
+		// Keep a second link to the file so that we can inspect it afterwards.
+		link := filepath.Join(dir, "link")
+		if err := os.Link(path, link); err != nil {
+			t.Skip("hard links unsupported:", err)
+		}
+
+		b, err := NewBufferFromFile(path, FileOptions{Shred: true})
+		if err != nil {
+			t.Error(mode, "unexpected error:", err)
+		}
+		if !b.EqualTo(data) {
+			t.Error(mode, "data does not match")
+		}
+		b.Destroy()
+
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Error(mode, "file should have been removed")
+		}
+		shredded, err := os.ReadFile(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(shredded) != len(data) {
+			t.Error(mode, "file size changed")
+		}
+		if bytes.Contains(shredded, []byte("yellow submarine")) {
+			t.Error(mode, "file contents not overwritten")
+		}
+	}
+}
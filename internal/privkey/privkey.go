@@ -0,0 +1,47 @@
+/*
+Package privkey holds helpers for handling parsed private keys that are shared between the subpackages of memguard.
+*/
+package privkey
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"math/big"
+
+	"github.com/awnumar/memguard/core"
+)
+
+// Wipe overwrites the secret values of a parsed private key. Keys of unrecognised types are left untouched.
+func Wipe(key crypto.PrivateKey) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		wipeInt(k.D)
+		for _, p := range k.Primes {
+			wipeInt(p)
+		}
+		wipeInt(k.Precomputed.Dp)
+		wipeInt(k.Precomputed.Dq)
+		wipeInt(k.Precomputed.Qinv)
+		for _, v := range k.Precomputed.CRTValues {
+			wipeInt(v.Exp)
+			wipeInt(v.Coeff)
+			wipeInt(v.R)
+		}
+	case *ecdsa.PrivateKey:
+		wipeInt(k.D)
+	case ed25519.PrivateKey:
+		core.Wipe(k)
+	}
+}
+
+func wipeInt(i *big.Int) {
+	if i == nil {
+		return
+	}
+	words := i.Bits()
+	for j := range words {
+		words[j] = 0
+	}
+}
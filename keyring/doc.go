@@ -0,0 +1,16 @@
+/*
+Package keyring stores the contents of enclaves inside the Linux kernel's key retention service.
+
+Keys held by the kernel outlive the process that created them and can be shared between processes of the same user, without the secret ever being written to disk. Secrets are always read back straight into locked memory.
+
+	// Move a secret into the user keyring for an hour.
+	key, err := keyring.Store(secret, "db-password", keyring.User, keyring.Options{Timeout: time.Hour})
+
+	// In another process, find it again and read it back.
+	key, err = keyring.Search("db-password", keyring.User)
+	buf, err := key.Open()
+	defer buf.Destroy()
+
+This package is only available on Linux.
+*/
+package keyring
@@ -0,0 +1,239 @@
+package keyring
+
+import (
+	"errors"
+	"time"
+
+	"github.com/awnumar/memguard"
+	"golang.org/x/sys/unix"
+)
+
+// ErrNullKey is returned when attempting to store an empty secret, which the kernel does not allow.
+var ErrNullKey = errors.New("<memguard::keyring::ErrNullKey> key payload must not be empty")
+
+// keyType is the kernel key type used for all keys. Its payload can be read back from userspace.
+const keyType = "user"
+
+// Keyring identifies one of the special keyrings that are available to a process.
+type Keyring int
+
+const (
+	// Thread is the keyring specific to the calling OS thread. Go may move goroutines between threads, so this is rarely useful.
+	Thread Keyring = unix.KEY_SPEC_THREAD_KEYRING
+
+	// Process is the keyring shared by all threads of the process. It is destroyed when the process exits.
+	Process Keyring = unix.KEY_SPEC_PROCESS_KEYRING
+
+	// Session is the keyring of the current login session, inherited by child processes.
+	Session Keyring = unix.KEY_SPEC_SESSION_KEYRING
+
+	// User is the keyring shared by all processes of the current user. Its keys are only possessed by processes whose session keyring links to it, so keys stored here usually need UserRead and UserSearch permissions to be read back by other processes.
+	User Keyring = unix.KEY_SPEC_USER_KEYRING
+)
+
+// Perm is a set of permissions on a key. A key has separate permissions for its possessor, the owning user, the owning group and everyone else.
+type Perm uint32
+
+// Permissions that may be granted on a key.
+const (
+	PossessorView    Perm = 0x01000000
+	PossessorRead    Perm = 0x02000000
+	PossessorWrite   Perm = 0x04000000
+	PossessorSearch  Perm = 0x08000000
+	PossessorLink    Perm = 0x10000000
+	PossessorSetattr Perm = 0x20000000
+	PossessorAll     Perm = 0x3f000000
+
+	UserView    Perm = 0x00010000
+	UserRead    Perm = 0x00020000
+	UserWrite   Perm = 0x00040000
+	UserSearch  Perm = 0x00080000
+	UserLink    Perm = 0x00100000
+	UserSetattr Perm = 0x00200000
+	UserAll     Perm = 0x003f0000
+
+	GroupView    Perm = 0x00000100
+	GroupRead    Perm = 0x00000200
+	GroupWrite   Perm = 0x00000400
+	GroupSearch  Perm = 0x00000800
+	GroupLink    Perm = 0x00001000
+	GroupSetattr Perm = 0x00002000
+	GroupAll     Perm = 0x00003f00
+
+	OtherView    Perm = 0x00000001
+	OtherRead    Perm = 0x00000002
+	OtherWrite   Perm = 0x00000004
+	OtherSearch  Perm = 0x00000008
+	OtherLink    Perm = 0x00000010
+	OtherSetattr Perm = 0x00000020
+	OtherAll     Perm = 0x0000003f
+)
+
+// Options configures a newly stored key. The zero value leaves the kernel's defaults in place.
+type Options struct {
+	// Timeout is the duration after which the kernel expires the key. It is rounded up to the nearest second. Zero means the key does not expire.
+	Timeout time.Duration
+
+	// Perm replaces the default permissions of the key when it is non-zero.
+	Perm Perm
+}
+
+// Key is the serial number of a key held by the kernel.
+type Key int
+
+/*
+Store decrypts an Enclave and moves its contents into a key in the given keyring, replacing any existing key with the same description. The plaintext is only ever held inside a LockedBuffer on the way to the kernel. The Enclave is left untouched.
+*/
+func Store(e *memguard.Enclave, description string, ring Keyring, opts Options) (Key, error) {
+	b, err := e.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer b.Destroy()
+
+	return StoreBuffer(b, description, ring, opts)
+}
+
+/*
+StoreBuffer copies the contents of a LockedBuffer into a key in the given keyring, replacing any existing key with the same description.
+
+If an error occurs after the key was created, the key is invalidated. In the unlikely event that this fails too, the key is returned along with the error so that the caller can deal with it.
+*/
+func StoreBuffer(b *memguard.LockedBuffer, description string, ring Keyring, opts Options) (Key, error) {
+	if b.Size() == 0 {
+		return 0, ErrNullKey
+	}
+
+	// We can only change the attributes of a key that we possess, which is not guaranteed for keys in other keyrings. So stage the key in the process keyring and link it into place afterwards.
+	staged := ring != Process && opts != (Options{})
+	dest := ring
+	if staged {
+		dest = Process
+	}
+
+	b.RLock()
+	id, err := unix.AddKey(keyType, description, b.Bytes(), int(dest))
+	b.RUnlock()
+	if err != nil {
+		return 0, err
+	}
+	k := Key(id)
+
+	// Set the timeout first as the new permissions may not allow it to be changed.
+	if opts.Timeout != 0 {
+		if err := k.SetTimeout(opts.Timeout); err != nil {
+			k.Invalidate()
+			return 0, err
+		}
+	}
+
+	if staged {
+		// Link the key into place before changing its permissions, as they may not allow it to be linked. Linking displaces any existing key with the same description.
+		if _, err := unix.KeyctlInt(unix.KEYCTL_LINK, int(k), int(ring), 0, 0); err != nil {
+			k.Invalidate()
+			return 0, err
+		}
+	}
+
+	// The key is still in the process keyring, so we possess it.
+	if opts.Perm != 0 {
+		if err := k.SetPerm(opts.Perm); err != nil {
+			k.Invalidate()
+			return 0, err
+		}
+	}
+
+	if staged {
+		if _, err := unix.KeyctlInt(unix.KEYCTL_UNLINK, int(k), int(Process), 0, 0); err != nil {
+			// Don't leave a live key behind. If we can't get rid of it, let the caller deal with it.
+			if k.Invalidate() != nil {
+				return k, err
+			}
+			return 0, err
+		}
+	}
+
+	return k, nil
+}
+
+/*
+Search looks for a key with the given description in a keyring and any keyrings nested within it.
+*/
+func Search(description string, ring Keyring) (Key, error) {
+	id, err := unix.KeyctlSearch(int(ring), keyType, description, 0)
+	if err != nil {
+		return 0, err
+	}
+	return Key(id), nil
+}
+
+/*
+Open reads the payload of a key directly into an immutable LockedBuffer.
+*/
+func (k Key) Open() (*memguard.LockedBuffer, error) {
+	// Find out how large the payload is.
+	size, err := unix.KeyctlBuffer(unix.KEYCTL_READ, int(k), nil, 0)
+	if err != nil {
+		return memguard.NewBuffer(0), err
+	}
+
+	for {
+		b := memguard.NewBuffer(size)
+		if b.Size() == 0 {
+			return b, ErrNullKey
+		}
+
+		n, err := unix.KeyctlBuffer(unix.KEYCTL_READ, int(k), b.Bytes(), 0)
+		if err != nil {
+			b.Destroy()
+			return memguard.NewBuffer(0), err
+		}
+
+		// The key was updated in between our calls and grew, so try again.
+		if n > size {
+			b.Destroy()
+			size = n
+			continue
+		}
+
+		// It may also have shrunk.
+		if n < size {
+			d := memguard.NewBuffer(n)
+			d.Copy(b.Bytes()[:n])
+			b.Destroy()
+			b = d
+		}
+
+		b.Freeze()
+		return b, nil
+	}
+}
+
+/*
+Seal reads the payload of a key and returns it sealed inside an Enclave.
+*/
+func (k Key) Seal() (*memguard.Enclave, error) {
+	b, err := k.Open()
+	if err != nil {
+		return nil, err
+	}
+	return b.Seal(), nil
+}
+
+// SetTimeout sets the duration after which the kernel expires the key, rounded up to the nearest second. A zero duration clears any existing timeout.
+func (k Key) SetTimeout(d time.Duration) error {
+	secs := int((d + time.Second - 1) / time.Second)
+	_, err := unix.KeyctlInt(unix.KEYCTL_SET_TIMEOUT, int(k), secs, 0, 0)
+	return err
+}
+
+// SetPerm replaces the permissions of the key.
+func (k Key) SetPerm(perm Perm) error {
+	return unix.KeyctlSetperm(int(k), uint32(perm))
+}
+
+// Invalidate immediately destroys the key and wipes its payload from kernel memory.
+func (k Key) Invalidate() error {
+	_, err := unix.KeyctlInt(unix.KEYCTL_INVALIDATE, int(k), 0, 0, 0)
+	return err
+}
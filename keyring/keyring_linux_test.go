@@ -0,0 +1,196 @@
+package keyring
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awnumar/memguard"
+	"golang.org/x/sys/unix"
+)
+
+// Returns a description that will not collide with other test runs.
+func description(t *testing.T) string {
+	return fmt.Sprintf("memguard-test-%s-%d", t.Name(), os.Getpid())
+}
+
+// Stores a secret or skips the test if the kernel does not let us use keyrings.
+func store(t *testing.T, e *memguard.Enclave, ring Keyring, opts Options) Key {
+	k, err := Store(e, description(t), ring, opts)
+	if err == unix.ENOSYS || err == unix.EPERM || err == unix.EACCES {
+		t.Skip("keyrings unavailable:", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestStoreOpen(t *testing.T) {
+	for _, ring := range []Keyring{Process, Session, User} {
+		// The user keyring may not be linked to our session, in which case we do not possess its keys.
+		var opts Options
+		if ring == User {
+			opts.Perm = PossessorAll | UserView | UserRead | UserSearch
+		}
+		k := store(t, memguard.NewEnclave([]byte("yellow submarine")), ring, opts)
+
+		b, err := k.Open()
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+		if !b.EqualTo([]byte("yellow submarine")) {
+			t.Error("data does not match")
+		}
+		if b.IsMutable() {
+			t.Error("buffer should be immutable")
+		}
+		b.Destroy()
+
+		found, err := Search(description(t), ring)
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+		if found != k {
+			t.Error("search returned a different key")
+		}
+
+		e, err := found.Seal()
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+		b, err = e.Open()
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+		if !b.EqualTo([]byte("yellow submarine")) {
+			t.Error("data does not match")
+		}
+		b.Destroy()
+
+		if err := k.Invalidate(); err != nil {
+			t.Error("unexpected error:", err)
+		}
+		b, err = k.Open()
+		if err == nil {
+			t.Error("expected error reading invalidated key")
+		}
+		if b.IsAlive() {
+			t.Error("buffer should be destroyed")
+		}
+		if _, err := Search(description(t), ring); err == nil {
+			t.Error("expected error searching for invalidated key")
+		}
+	}
+}
+
+func TestStoreEmpty(t *testing.T) {
+	if _, err := StoreBuffer(memguard.NewBuffer(0), description(t), Process, Options{}); err != ErrNullKey {
+		t.Error("expected ErrNullKey; got", err)
+	}
+}
+
+func TestStoreReplace(t *testing.T) {
+	k1 := store(t, memguard.NewEnclave([]byte("yellow submarine")), Process, Options{})
+	k2 := store(t, memguard.NewEnclave([]byte("hunter2")), Process, Options{})
+	defer k2.Invalidate()
+
+	if k1 != k2 {
+		t.Error("expected the existing key to be updated")
+	}
+	b, err := k1.Open()
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !b.EqualTo([]byte("hunter2")) {
+		t.Error("key was not updated")
+	}
+	b.Destroy()
+}
+
+func TestOptions(t *testing.T) {
+	k := store(t, memguard.NewEnclave([]byte("yellow submarine")), Process, Options{
+		Perm:    PossessorAll | UserView,
+		Timeout: time.Second,
+	})
+	defer k.Invalidate()
+
+	// The description has the form type;uid;gid;perm;description.
+	desc, err := unix.KeyctlString(unix.KEYCTL_DESCRIBE, int(k))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Split(desc, ";")
+	if len(fields) != 5 || fields[3] != "3f010000" {
+		t.Error("unexpected permissions in description", desc)
+	}
+
+	// Wait for the key to expire.
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := k.Open(); err == nil {
+		t.Error("expected error reading expired key")
+	}
+}
+
+func TestRestrictedPerm(t *testing.T) {
+	// Make sure keyrings are usable, so that a permission error below is a failure.
+	store(t, memguard.NewEnclave([]byte("probe")), Process, Options{}).Invalidate()
+
+	// Linking the key is not permitted once it is in place.
+	k, err := Store(memguard.NewEnclave([]byte("yellow submarine")), description(t), Session, Options{
+		Perm: PossessorView | PossessorRead | PossessorSearch | UserView,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer k.Invalidate()
+
+	desc, err := unix.KeyctlString(unix.KEYCTL_DESCRIBE, int(k))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Split(desc, ";")
+	if len(fields) != 5 || fields[3] != "0b010000" {
+		t.Error("unexpected permissions in description", desc)
+	}
+
+	b, err := k.Open()
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !b.EqualTo([]byte("yellow submarine")) {
+		t.Error("data does not match")
+	}
+	b.Destroy()
+}
+
+func TestShareWithChild(t *testing.T) {
+	// If we're within the testing subprocess, read the key left by our parent.
+	if os.Getenv("WITHIN_SUBPROCESS") == "1" {
+		k, err := Search(os.Getenv("KEY_DESCRIPTION"), Session)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := k.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer b.Destroy()
+		if !b.EqualTo([]byte("yellow submarine")) {
+			t.Error("data does not match")
+		}
+		return
+	}
+
+	k := store(t, memguard.NewEnclave([]byte("yellow submarine")), Session, Options{})
+	defer k.Invalidate()
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestShareWithChild")
+	cmd.Env = append(os.Environ(), "WITHIN_SUBPROCESS=1", "KEY_DESCRIPTION="+description(t))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Error("subprocess failed:", err, "\n", string(out))
+	}
+}
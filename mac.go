@@ -0,0 +1,62 @@
+package memguard
+
+import (
+	"github.com/awnumar/memguard/core"
+)
+
+/*
+MAC computes the HMAC-SHA256 of a message using the contents of an Enclave as the key, returning a tag of core.MACSize bytes. The key and every hash state derived from it are only ever held inside LockedBuffers, which are destroyed before the function returns. An error is returned if the Enclave could not be decrypted.
+*/
+func (e *Enclave) MAC(msg []byte) ([]byte, error) {
+	return e.mac(msg, func(dst, key, msg, scratch []byte) error {
+		core.HMACSHA256(dst, key, msg, scratch)
+		return nil
+	})
+}
+
+/*
+VerifyMAC computes the HMAC-SHA256 of a message using the contents of an Enclave as the key and compares it against the given tag in constant time.
+*/
+func (e *Enclave) VerifyMAC(msg, tag []byte) (bool, error) {
+	expected, err := e.MAC(msg)
+	if err != nil {
+		return false, err
+	}
+	return core.Equal(expected, tag), nil
+}
+
+/*
+MACBlake2b computes the keyed BLAKE2b-256 hash of a message using the contents of an Enclave as the key, returning a tag of core.MACSize bytes. The key must be at most 64 bytes long, otherwise core.ErrInvalidMACKey is returned. As with MAC, the key and derived hash state are only ever held inside LockedBuffers.
+*/
+func (e *Enclave) MACBlake2b(msg []byte) ([]byte, error) {
+	return e.mac(msg, core.Blake2bMAC)
+}
+
+/*
+VerifyMACBlake2b computes the keyed BLAKE2b-256 hash of a message using the contents of an Enclave as the key and compares it against the given tag in constant time.
+*/
+func (e *Enclave) VerifyMACBlake2b(msg, tag []byte) (bool, error) {
+	expected, err := e.MACBlake2b(msg)
+	if err != nil {
+		return false, err
+	}
+	return core.Equal(expected, tag), nil
+}
+
+// Opens the key and computes a tag with the given function, keeping all intermediate state in locked memory.
+func (e *Enclave) mac(msg []byte, f func(dst, key, msg, scratch []byte) error) ([]byte, error) {
+	key, err := e.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer key.Destroy()
+
+	scratch := NewBuffer(core.MACScratchSize)
+	defer scratch.Destroy()
+
+	tag := make([]byte, core.MACSize)
+	if err := f(tag, key.Bytes(), msg, scratch.Bytes()); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
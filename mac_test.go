@@ -0,0 +1,79 @@
+package memguard
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/awnumar/memguard/core"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestMAC(t *testing.T) {
+	key := []byte("yellow submarine")
+	msg := []byte("the quick brown fox jumps over the lazy dog")
+
+	ref := hmac.New(sha256.New, key)
+	ref.Write(msg)
+	want := ref.Sum(nil)
+
+	e := NewEnclave(append([]byte{}, key...))
+	tag, err := e.MAC(msg)
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !bytes.Equal(tag, want) {
+		t.Error("tag does not match reference implementation")
+	}
+
+	ok, err := e.VerifyMAC(msg, want)
+	if err != nil || !ok {
+		t.Error("valid tag rejected:", err)
+	}
+	want[0] ^= 1
+	ok, err = e.VerifyMAC(msg, want)
+	if err != nil || ok {
+		t.Error("invalid tag accepted:", err)
+	}
+	ok, err = e.VerifyMAC(msg, want[:16])
+	if err != nil || ok {
+		t.Error("truncated tag accepted:", err)
+	}
+}
+
+func TestMACBlake2b(t *testing.T) {
+	key := []byte("yellow submarine")
+	msg := []byte("the quick brown fox jumps over the lazy dog")
+
+	ref, err := blake2b.New256(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref.Write(msg)
+	want := ref.Sum(nil)
+
+	e := NewEnclave(append([]byte{}, key...))
+	tag, err := e.MACBlake2b(msg)
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !bytes.Equal(tag, want) {
+		t.Error("tag does not match reference implementation")
+	}
+
+	ok, err := e.VerifyMACBlake2b(msg, want)
+	if err != nil || !ok {
+		t.Error("valid tag rejected:", err)
+	}
+	want[0] ^= 1
+	ok, err = e.VerifyMACBlake2b(msg, want)
+	if err != nil || ok {
+		t.Error("invalid tag accepted:", err)
+	}
+
+	// Keys that are too long are rejected.
+	if _, err := NewEnclaveRandom(65).MACBlake2b(msg); err != core.ErrInvalidMACKey {
+		t.Error("expected ErrInvalidMACKey; got", err)
+	}
+}
@@ -0,0 +1,86 @@
+package memguard
+
+import (
+	"io"
+	"os"
+
+	"github.com/awnumar/memguard/core"
+)
+
+// Control characters that are interpreted while reading a password.
+const (
+	keyBackspace = 0x08 // ^H
+	keyDelete    = 0x7f // what most terminals send for backspace
+	keyKillLine  = 0x15 // ^U
+	keyEOF       = 0x04 // ^D
+)
+
+/*
+readPassword reads a single line of input from an io.Reader into an immutable LockedBuffer. Line editing is performed inside the locked allocation: backspace removes the previous character and ^U discards the whole line. The line is terminated by a newline or carriage return, neither of which is included in the result.
+
+If the input ends before any data is read, io.EOF is returned. If it ends after some data was read, the data is returned with a nil error. Any other error causes the partial input to be destroyed and the error to be returned.
+*/
+func readPassword(r io.Reader) (*LockedBuffer, error) {
+	// Construct a buffer with a data page that fills an entire memory page.
+	b := NewBuffer(os.Getpagesize())
+
+	i := 0 // number of bytes in the line so far
+loop:
+	for {
+		// If we have filled this buffer, grow it by another page size.
+		if i == b.Size() {
+			c := NewBuffer(b.Size() + os.Getpagesize())
+			c.Copy(b.Bytes())
+			b.Destroy()
+			b = c
+		}
+
+		// Attempt to read a single byte directly into the buffer.
+		n, err := r.Read(b.Bytes()[i : i+1])
+		if n != 1 {
+			if err == nil {
+				continue // try again
+			}
+			if err == io.EOF && i > 0 {
+				break loop
+			}
+			b.Destroy()
+			return newNullBuffer(), err
+		}
+
+		switch b.Bytes()[i] {
+		case '\n', '\r':
+			b.Bytes()[i] = 0
+			break loop
+		case keyBackspace, keyDelete:
+			b.Bytes()[i] = 0
+			if i > 0 {
+				i--
+				b.Bytes()[i] = 0
+			}
+		case keyKillLine:
+			core.Wipe(b.Bytes()[:i+1])
+			i = 0
+		case keyEOF:
+			b.Bytes()[i] = 0
+			if i == 0 {
+				b.Destroy()
+				return newNullBuffer(), io.EOF
+			}
+			break loop
+		default:
+			i++
+		}
+	}
+
+	if i == 0 {
+		// empty line, there's no data to return
+		b.Destroy()
+		return newNullBuffer(), nil
+	}
+	d := NewBuffer(i)
+	d.Copy(b.Bytes()[:i])
+	d.Freeze()
+	b.Destroy()
+	return d, nil
+}
@@ -0,0 +1,159 @@
+package memguard
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Opens a new pseudo-terminal pair, returning the master and slave ends.
+func openPty(t *testing.T) (*os.File, *os.File) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skip("pseudo-terminals unavailable:", err)
+	}
+	if err := unix.IoctlSetPointerInt(int(master.Fd()), unix.TIOCSPTLCK, 0); err != nil {
+		t.Fatal(err)
+	}
+	n, err := unix.IoctlGetInt(int(master.Fd()), unix.TIOCGPTN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slave, err := os.OpenFile("/dev/pts/"+strconv.Itoa(n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return master, slave
+}
+
+// Waits until echo has been disabled on a terminal.
+func waitForNoEcho(fd int) bool {
+	for i := 0; i < 500; i++ {
+		tios, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+		if err == nil && tios.Lflag&unix.ECHO == 0 {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestReadPasswordPty(t *testing.T) {
+	master, slave := openPty(t)
+	defer master.Close()
+	defer slave.Close()
+
+	type result struct {
+		b   *LockedBuffer
+		err error
+	}
+	done := make(chan result)
+	go func() {
+		b, err := ReadPassword("Password: ", int(slave.Fd()))
+		done <- result{b, err}
+	}()
+
+	// Read back the prompt.
+	prompt := make([]byte, len("Password: "))
+	if _, err := master.Read(prompt); err != nil {
+		t.Fatal(err)
+	}
+	if string(prompt) != "Password: " {
+		t.Error("unexpected prompt:", string(prompt))
+	}
+	if !waitForNoEcho(int(slave.Fd())) {
+		t.Fatal("echo was not disabled")
+	}
+
+	// Type a password with a correction, then hit enter.
+	if _, err := master.Write([]byte("hunter3\x7f2\r")); err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-done
+	if r.err != nil {
+		t.Error("unexpected error:", r.err)
+	}
+	if !r.b.EqualTo([]byte("hunter2")) {
+		t.Errorf("unexpected password %q", r.b.Bytes())
+	}
+	r.b.Destroy()
+
+	// Only the newline should have been written; nothing typed was echoed.
+	out := make([]byte, 64)
+	n, err := master.Read(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(out[:n], []byte("hunter")) || !bytes.Contains(out[:n], []byte("\n")) {
+		t.Errorf("unexpected terminal output %q", out[:n])
+	}
+
+	// The terminal settings should be back to normal.
+	tios, err := unix.IoctlGetTermios(int(slave.Fd()), ioctlReadTermios)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tios.Lflag&unix.ECHO == 0 || tios.Lflag&unix.ICANON == 0 {
+		t.Error("terminal settings were not restored")
+	}
+
+	// Not a terminal.
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if b, err := ReadPassword("", int(f.Fd())); err == nil || b.IsAlive() {
+		t.Error("expected error for non-terminal")
+	}
+}
+
+func TestReadPasswordSignal(t *testing.T) {
+	// If we're within the testing subprocess, run test.
+	if os.Getenv("WITHIN_SUBPROCESS") == "1" {
+		// The terminal is passed to us as the first extra file.
+		CatchInterrupt()
+		go ReadPassword("", 3)
+
+		// Wait until echo is disabled before interrupting ourselves.
+		if !waitForNoEcho(3) {
+			os.Exit(2)
+		}
+		process, err := os.FindProcess(os.Getpid())
+		if err != nil {
+			os.Exit(2)
+		}
+		process.Signal(os.Interrupt)
+		select {}
+	}
+
+	master, slave := openPty(t)
+	defer master.Close()
+	defer slave.Close()
+
+	// Construct the subprocess with its initial state
+	cmd := exec.Command(os.Args[0], "-test.run=TestReadPasswordSignal")
+	cmd.Env = append(os.Environ(), "WITHIN_SUBPROCESS=1")
+	cmd.ExtraFiles = []*os.File{slave}
+
+	// Execute the subprocess and inspect its exit code
+	err := cmd.Run().(*exec.ExitError)
+	if err.ExitCode() != 1 {
+		t.Error("Wanted exit code 1, got", err.ExitCode(), "err:", err)
+	}
+
+	// The subprocess should have restored the terminal before exiting.
+	tios, terr := unix.IoctlGetTermios(int(slave.Fd()), ioctlReadTermios)
+	if terr != nil {
+		t.Fatal(terr)
+	}
+	if tios.Lflag&unix.ECHO == 0 {
+		t.Error("terminal settings were not restored on signal")
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package memguard
+
+import "errors"
+
+/*
+ReadPassword writes a prompt to the terminal referred to by a file descriptor and reads a line of input from it directly into an immutable LockedBuffer, without echoing the input back.
+
+It is not supported on this platform and always returns an error.
+*/
+func ReadPassword(prompt string, fd int) (*LockedBuffer, error) {
+	return newNullBuffer(), errors.New("<memguard::ReadPassword> reading from a terminal is not supported on this platform")
+}
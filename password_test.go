@@ -0,0 +1,66 @@
+package memguard
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReadPassword(t *testing.T) {
+	for _, c := range []struct {
+		input string
+		want  string
+		err   error
+	}{
+		{"hunter2\n", "hunter2", nil},
+		{"hunter2\r", "hunter2", nil},
+		{"hunter2", "hunter2", nil},
+		{"hunter3\x7f2\n", "hunter2", nil},
+		{"hunter3\x082\n", "hunter2", nil},
+		{"\x7f\x7fhunter2\n", "hunter2", nil},
+		{"wrong\x15hunter2\n", "hunter2", nil},
+		{"hunter2\x04ignored", "hunter2", nil},
+		{"hunter2\ntrailing", "hunter2", nil},
+		{"\n", "", nil},
+		{"abc\x7f\x7f\x7f\n", "", nil},
+		{"", "", io.EOF},
+		{"\x04", "", io.EOF},
+	} {
+		b, err := readPassword(strings.NewReader(c.input))
+		if err != c.err {
+			t.Errorf("%q: expected error %v; got %v", c.input, c.err, err)
+		}
+		if !b.EqualTo([]byte(c.want)) {
+			t.Errorf("%q: expected %q; got %q", c.input, c.want, b.Bytes())
+		}
+		if b.Size() != 0 && b.IsMutable() {
+			t.Errorf("%q: buffer should be immutable", c.input)
+		}
+		b.Destroy()
+	}
+
+	// Input longer than a page.
+	input := bytes.Repeat([]byte{'x'}, os.Getpagesize()+10)
+	b, err := readPassword(bytes.NewReader(append(input, '\n')))
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !b.EqualTo(input) {
+		t.Error("data mismatch; got size", b.Size())
+	}
+	b.Destroy()
+
+	// Errors other than EOF discard the partial input.
+	e := errors.New("test error")
+	b, err = readPassword(io.MultiReader(strings.NewReader("hunter2"), iotest.ErrReader(e)))
+	if err != e {
+		t.Error("expected test error; got", err)
+	}
+	if b.IsAlive() {
+		t.Error("partial input should be destroyed")
+	}
+}
@@ -0,0 +1,94 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package memguard
+
+import (
+	"io"
+
+	"golang.org/x/sys/unix"
+)
+
+/*
+ReadPassword writes a prompt to the terminal referred to by a file descriptor and reads a line of input from it directly into an immutable LockedBuffer, without echoing the input back.
+
+The terminal is switched out of canonical mode for the duration of the call so that the kernel's line editing is disabled and backspace and ^U are instead handled inside locked memory. The previous terminal settings are restored before returning. If CatchSignal or CatchInterrupt is active they are also restored if a signal terminates the process during the call.
+
+An empty line results in a destroyed LockedBuffer being returned. If the input ends before any data is read, io.EOF is returned.
+*/
+func ReadPassword(prompt string, fd int) (*LockedBuffer, error) {
+	// Save the current terminal settings.
+	old, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		return newNullBuffer(), err
+	}
+
+	// Disable echo and canonical mode but keep signals generated by the terminal.
+	t := *old
+	t.Lflag &^= unix.ECHO | unix.ICANON
+	t.Lflag |= unix.ISIG
+	t.Iflag |= unix.ICRNL
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlWriteTermios, &t); err != nil {
+		return newNullBuffer(), err
+	}
+
+	// Make sure the settings are restored however we leave.
+	restore := func() {
+		unix.IoctlSetTermios(fd, ioctlWriteTermios, old)
+	}
+	id := cleanups.add(restore)
+	defer cleanups.remove(id)
+	defer restore()
+
+	if err := writeFull(fd, []byte(prompt)); err != nil {
+		return newNullBuffer(), err
+	}
+
+	b, err := readPassword(fdReader(fd))
+	if err != nil {
+		return b, err
+	}
+
+	// The newline was not echoed so output one ourselves.
+	if err := writeFull(fd, []byte("\n")); err != nil {
+		b.Destroy()
+		return newNullBuffer(), err
+	}
+	return b, nil
+}
+
+// fdReader implements io.Reader on a raw file descriptor. It is used instead of os.File so that the descriptor is not closed by a finalizer.
+type fdReader int
+
+func (r fdReader) Read(p []byte) (int, error) {
+	for {
+		n, err := unix.Read(int(r), p)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 && len(p) > 0 {
+			return 0, io.EOF
+		}
+		return n, nil
+	}
+}
+
+// Writes all of the data to a raw file descriptor.
+func writeFull(fd int, data []byte) error {
+	for len(data) > 0 {
+		n, err := unix.Write(fd, data)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package memguard
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/awnumar/memguard/core"
+)
+
+/*
+Pipe creates a synchronous in-memory pipe for passing sensitive data between goroutines. It can be used to connect code expecting an io.Reader with code expecting an io.Writer.
+
+Unlike io.Pipe, the pipe is buffered: up to capacity bytes written to it are held inside a Stream, encrypted, until they are read. Writes block while the pipe is full and reads block while it is empty. If capacity is less than one, the value of StreamChunkSize is used.
+
+The reader and writer are safe to use from multiple goroutines, and closing them behaves as it does for io.Pipe.
+*/
+func Pipe(capacity int) (*PipeReader, *PipeWriter) {
+	if capacity < 1 {
+		capacity = StreamChunkSize
+	}
+	p := &pipe{
+		s:        NewStreamWithOptions(StreamOptions{ChunkSize: min(capacity, StreamChunkSize)}),
+		capacity: capacity,
+		changed:  make(chan struct{}),
+	}
+	return &PipeReader{p}, &PipeWriter{p}
+}
+
+type pipe struct {
+	sync.Mutex
+	wrMu sync.Mutex // serializes writes, so that concurrent writes are not interleaved
+
+	s        *Stream
+	size     int // number of bytes held in s
+	capacity int
+
+	changed chan struct{} // closed and replaced whenever the state of the pipe changes
+
+	rerr error // set once the read half is closed
+	werr error // set once the write half is closed
+}
+
+// PipeReader is the read half of a pipe created by Pipe.
+type PipeReader struct {
+	p *pipe
+}
+
+/*
+Read reads data from the pipe into a provided buffer, blocking until data is available or the write half is closed. Small writes are coalesced, so a single call may return data from several of them, but it returns at most one chunk of data.
+
+If the write half was closed, io.EOF or the error it was closed with is returned once all of the data has been read. If the read half was closed, io.ErrClosedPipe is returned.
+*/
+func (r *PipeReader) Read(buf []byte) (int, error) {
+	return r.p.read(context.Background(), buf)
+}
+
+// ReadContext is like Read but returns the error of the context if it is done before any data becomes available.
+func (r *PipeReader) ReadContext(ctx context.Context, buf []byte) (int, error) {
+	return r.p.read(ctx, buf)
+}
+
+// Close closes the reader. Any data held in the pipe is destroyed, and subsequent writes to the write half return io.ErrClosedPipe.
+func (r *PipeReader) Close() error {
+	return r.CloseWithError(nil)
+}
+
+// CloseWithError closes the reader, destroying any data held in the pipe. Subsequent writes to the write half return err, or io.ErrClosedPipe if err is nil. It never overwrites an error that the reader was previously closed with, and always returns nil.
+func (r *PipeReader) CloseWithError(err error) error {
+	if err == nil {
+		err = io.ErrClosedPipe
+	}
+	r.p.closeRead(err)
+	return nil
+}
+
+// PipeWriter is the write half of a pipe created by Pipe.
+type PipeWriter struct {
+	p *pipe
+}
+
+/*
+Write encrypts and writes some given data to the pipe, blocking until all of it has been written or either half of the pipe is closed. The data is wiped as it is written, and any that could not be written is wiped before returning. Concurrent writes are serialized, so the data of each is kept together.
+
+If the read half was closed, the error it was closed with is returned. If the write half was closed, io.ErrClosedPipe is returned.
+*/
+func (w *PipeWriter) Write(data []byte) (int, error) {
+	return w.p.write(data)
+}
+
+// Close closes the writer. Once the data held in the pipe has been read, subsequent reads from the read half return io.EOF.
+func (w *PipeWriter) Close() error {
+	return w.CloseWithError(nil)
+}
+
+// CloseWithError closes the writer. Once the data held in the pipe has been read, subsequent reads from the read half return err, or io.EOF if err is nil. It never overwrites an error that the writer was previously closed with, and always returns nil.
+func (w *PipeWriter) CloseWithError(err error) error {
+	if err == nil {
+		err = io.EOF
+	}
+	w.p.closeWrite(err)
+	return nil
+}
+
+func (p *pipe) read(ctx context.Context, buf []byte) (int, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	for {
+		if p.rerr != nil {
+			return 0, io.ErrClosedPipe
+		}
+		if p.size > 0 {
+			break
+		}
+		if p.werr != nil {
+			return 0, p.werr
+		}
+		if err := p.wait(ctx); err != nil {
+			return 0, err
+		}
+	}
+	if len(buf) == 0 {
+		return 0, nil
+	}
+
+	n, err := p.s.Read(buf)
+	p.size -= n
+	if err != nil {
+		p.size = p.s.Size() // data that could not be read has been discarded
+	}
+	p.notify()
+	return n, err
+}
+
+func (p *pipe) write(data []byte) (int, error) {
+	// Wipe anything that is not written.
+	defer core.Wipe(data)
+
+	p.wrMu.Lock()
+	defer p.wrMu.Unlock()
+
+	p.Lock()
+	defer p.Unlock()
+
+	var n int
+	for {
+		if p.werr != nil {
+			return n, io.ErrClosedPipe
+		}
+		if p.rerr != nil {
+			return n, p.rerr
+		}
+		if n == len(data) {
+			return n, nil
+		}
+		if p.size == p.capacity {
+			p.wait(context.Background())
+			continue
+		}
+
+		m := min(len(data)-n, p.capacity-p.size)
+		if _, err := p.s.Write(data[n : n+m]); err != nil {
+			p.size = p.s.Size() // data held in the stream may have been lost
+			p.notify()
+			return n, err
+		}
+		n += m
+		p.size += m
+		p.notify()
+	}
+}
+
+func (p *pipe) closeRead(err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.rerr == nil {
+		p.rerr = err
+
+		// Drop the data, since it can no longer be read.
+		p.s.Close()
+		p.size = 0
+		p.notify()
+	}
+}
+
+func (p *pipe) closeWrite(err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.werr == nil {
+		p.werr = err
+		p.notify()
+	}
+}
+
+// Wakes up everything waiting on the pipe. Must be called with the mutex held.
+func (p *pipe) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// Releases the mutex until the state of the pipe changes or the context is done, reacquiring it before returning. Must be called with the mutex held.
+func (p *pipe) wait(ctx context.Context) error {
+	changed := p.changed
+	p.Unlock()
+	defer p.Lock()
+
+	select {
+	case <-changed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,212 @@
+package memguard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/awnumar/memguard/core"
+)
+
+func TestPipe(t *testing.T) {
+	r, w := Pipe(16)
+
+	ref := make([]byte, 1000)
+	ScrambleBytes(ref)
+
+	done := make(chan error)
+	go func() {
+		n, err := w.Write(append([]byte{}, ref...))
+		if err == nil && n != len(ref) {
+			err = io.ErrShortWrite
+		}
+		if err == nil {
+			err = w.Close()
+		}
+		done <- err
+	}()
+
+	// The writer should block once the pipe is full.
+	waitForFull(r.p)
+	select {
+	case <-done:
+		t.Error("writer should be blocked")
+	default:
+	}
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !bytes.Equal(out, ref) {
+		t.Error("data mismatch")
+	}
+	if err := <-done; err != nil {
+		t.Error("unexpected error from writer:", err)
+	}
+
+	// Both halves are closed now.
+	if _, err := w.Write([]byte("x")); err != io.ErrClosedPipe {
+		t.Error("expected io.ErrClosedPipe; got", err)
+	}
+	r.Close()
+	if _, err := r.Read(make([]byte, 1)); err != io.ErrClosedPipe {
+		t.Error("expected io.ErrClosedPipe; got", err)
+	}
+
+	// Default capacity.
+	r, _ = Pipe(0)
+	if r.p.capacity != StreamChunkSize {
+		t.Error("unexpected capacity", r.p.capacity)
+	}
+}
+
+// Waits until a pipe has been filled to capacity.
+func waitForFull(p *pipe) {
+	p.Lock()
+	defer p.Unlock()
+
+	for p.size < p.capacity {
+		p.wait(context.Background())
+	}
+}
+
+func TestPipeConcurrentWrites(t *testing.T) {
+	r, w := Pipe(16)
+
+	// Each writer writes a run of its own byte value.
+	done := make(chan struct{})
+	for i := 1; i <= 3; i++ {
+		go func(c byte) {
+			w.Write(bytes.Repeat([]byte{c}, 512))
+			done <- struct{}{}
+		}(byte(i))
+	}
+	go func() {
+		for i := 0; i < 3; i++ {
+			<-done
+		}
+		w.Close()
+	}()
+
+	// Reading in small pieces gives the writers many chances to race.
+	out, err := io.ReadAll(iotest.OneByteReader(r))
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if len(out) != 3*512 {
+		t.Error("unexpected length", len(out))
+	}
+	var switches int
+	for i := 1; i < len(out); i++ {
+		if out[i] != out[i-1] {
+			switches++
+		}
+	}
+	if switches != 2 {
+		t.Error("writes were interleaved; switched writer", switches, "times")
+	}
+}
+
+func TestPipeWriteError(t *testing.T) {
+	r, w := Pipe(16)
+	w.Write([]byte("yellow"))
+
+	// Purging destroys the data waiting to be sealed. The loss is reported once, and the pipe keeps working.
+	Purge()
+	if n, err := w.Write([]byte("submarine")); n != 0 || err != core.ErrBufferExpired {
+		t.Error("expected ErrBufferExpired; got", n, err)
+	}
+	if r.p.size != 0 {
+		t.Error("unexpected size", r.p.size)
+	}
+	w.Write([]byte("submarine"))
+	buf := make([]byte, 16)
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "submarine" {
+		t.Error("unexpected result from read", n, err)
+	}
+
+	// The same goes for a loss that is noticed by the reader.
+	w.Write([]byte("yellow"))
+	Purge()
+	if _, err := r.Read(buf); err != core.ErrBufferExpired {
+		t.Error("expected ErrBufferExpired; got", err)
+	}
+	w.Write([]byte("submarine"))
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "submarine" {
+		t.Error("unexpected result from read", n, err)
+	}
+}
+
+func TestPipeCloseWithError(t *testing.T) {
+	errTest := errors.New("test error")
+
+	// Buffered data is still delivered before the writer's error.
+	r, w := Pipe(16)
+	data := []byte("yellow submarine")
+	if _, err := w.Write(data); err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(data, make([]byte, len(data))) {
+		t.Error("buffer not wiped")
+	}
+	w.CloseWithError(errTest)
+	w.CloseWithError(nil) // should not overwrite
+	buf := make([]byte, 16)
+	if n, err := r.Read(buf); n != 16 || err != nil || string(buf) != "yellow submarine" {
+		t.Error("unexpected read", n, err)
+	}
+	if _, err := r.Read(buf); err != errTest {
+		t.Error("expected test error; got", err)
+	}
+
+	// Closing the reader unblocks and fails writes, destroying held data.
+	r, w = Pipe(4)
+	done := make(chan error)
+	data = []byte("yellow submarine")
+	go func() {
+		_, err := w.Write(data)
+		done <- err
+	}()
+	waitForFull(r.p)
+	r.CloseWithError(errTest)
+	if err := <-done; err != errTest {
+		t.Error("expected test error; got", err)
+	}
+	if !bytes.Equal(data, make([]byte, len(data))) {
+		t.Error("unwritten data not wiped")
+	}
+	if r.p.s.Size() != 0 || r.p.size != 0 {
+		t.Error("held data not destroyed")
+	}
+}
+
+func TestPipeReadContext(t *testing.T) {
+	r, w := Pipe(16)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := r.ReadContext(ctx, make([]byte, 4)); err != context.DeadlineExceeded {
+		t.Error("expected context.DeadlineExceeded; got", err)
+	}
+
+	// Data that is already available is returned regardless of the context.
+	w.Write([]byte("data"))
+	buf := make([]byte, 4)
+	if n, err := r.ReadContext(ctx, buf); n != 4 || err != nil || string(buf) != "data" {
+		t.Error("unexpected read", n, err)
+	}
+
+	// A blocked read is woken by a write.
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("more"))
+	}()
+	if n, err := r.ReadContext(context.Background(), buf); n != 4 || err != nil || string(buf) != "more" {
+		t.Error("unexpected read", n, err)
+	}
+}
@@ -0,0 +1,262 @@
+package memguard
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/awnumar/memguard/core"
+)
+
+// ErrInvalidOffset is returned when seeking, reading, writing or truncating at a negative offset.
+var ErrInvalidOffset = errors.New("<memguard::ErrInvalidOffset> offset must not be negative")
+
+/*
+SecretFileOptions configures a SecretFile created by NewSecretFileWithOptions.
+*/
+type SecretFileOptions struct {
+	// ChunkSize is the size of each encrypted chunk, and so the maximum amount of data that is decrypted at a time. If zero, the value of StreamChunkSize is used.
+	ChunkSize int
+}
+
+/*
+SecretFile is an in-memory encrypted file supporting random access. It implements io.Reader, io.Writer, io.ReaderAt, io.WriterAt and io.Seeker.
+
+Its contents are split into fixed size chunks, each sealed inside its own Enclave together with an identifier for the file and the index of the chunk, so chunks that are moved or swapped fail with ErrStreamCorrupt. Each operation decrypts only the chunks that it touches. Regions that have never been written read as zeroes and take up no memory.
+*/
+type SecretFile struct {
+	sync.Mutex
+
+	chunkSize int
+	chunks    []*Enclave // nil entries hold only zeroes
+	size      int64
+	offset    int64
+
+	id [streamIDSize]byte
+}
+
+// NewSecretFile creates a new empty SecretFile, with chunks of StreamChunkSize bytes.
+func NewSecretFile() *SecretFile {
+	return NewSecretFileWithOptions(SecretFileOptions{})
+}
+
+// NewSecretFileWithOptions creates a new empty SecretFile with the given options.
+func NewSecretFileWithOptions(opts SecretFileOptions) *SecretFile {
+	size := opts.ChunkSize
+	if size < 1 {
+		size = StreamChunkSize
+	}
+	if size < 1 {
+		size = defaultChunkSize
+	}
+	f := &SecretFile{chunkSize: size}
+	if err := core.Scramble(f.id[:]); err != nil {
+		core.Panic(err)
+	}
+	return f
+}
+
+// Size returns the length of the contents of a SecretFile.
+func (f *SecretFile) Size() int64 {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.size
+}
+
+/*
+ReadAt reads len(p) bytes from the SecretFile starting at byte offset off. It returns io.EOF if fewer than len(p) bytes could be read because the end of the file was reached.
+*/
+func (f *SecretFile) ReadAt(p []byte, off int64) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.readAt(p, off)
+}
+
+/*
+WriteAt writes len(p) bytes to the SecretFile starting at byte offset off, extending it if necessary. The source buffer is wiped after it has been written, as with Stream.Write.
+*/
+func (f *SecretFile) WriteAt(p []byte, off int64) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.writeAt(p, off)
+}
+
+// Read reads up to len(p) bytes from the current offset of the SecretFile, advancing it.
+func (f *SecretFile) Read(p []byte) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+	n, err := f.readAt(p, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Write writes len(p) bytes at the current offset of the SecretFile, advancing it. The source buffer is wiped.
+func (f *SecretFile) Write(p []byte) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	n, err := f.writeAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+/*
+Seek sets the offset for the next Read or Write, interpreted according to whence as with io.Seeker. Seeking beyond the end of the file is allowed; a subsequent Write will extend it.
+*/
+func (f *SecretFile) Seek(offset int64, whence int) (int64, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, errors.New("<memguard::SecretFile::Seek> invalid whence")
+	}
+	if offset < 0 {
+		return 0, ErrInvalidOffset
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+/*
+Truncate changes the size of the SecretFile. Shrinking it discards the chunks beyond the new size, and extending it adds zeroes. The offset is not changed.
+*/
+func (f *SecretFile) Truncate(size int64) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if size < 0 {
+		return ErrInvalidOffset
+	}
+
+	if size < f.size {
+		count := int((size + int64(f.chunkSize) - 1) / int64(f.chunkSize))
+		for i := count; i < len(f.chunks); i++ {
+			f.chunks[i] = nil
+		}
+		f.chunks = f.chunks[:min(count, len(f.chunks))]
+
+		// Trim the stored data of the new last chunk, so that it reads as zeroes if the file grows again.
+		if keep := int(size % int64(f.chunkSize)); keep > 0 && count <= len(f.chunks) && f.chunks[count-1] != nil {
+			b, err := openChunk(f.chunks[count-1], &f.id, uint64(count-1))
+			if err != nil {
+				return err
+			}
+			data := b.Bytes()[streamHeaderSize:]
+			if len(data) > keep {
+				c := NewBuffer(keep)
+				c.Copy(data[:keep])
+				f.chunks[count-1] = sealChunk(&f.id, uint64(count-1), c.Bytes())
+				c.Destroy()
+			}
+			b.Destroy()
+		}
+	}
+
+	f.size = size
+	return nil
+}
+
+// Does not acquire mutex lock.
+func (f *SecretFile) readAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidOffset
+	}
+	if off >= f.size {
+		return 0, io.EOF
+	}
+
+	n := 0
+	for n < len(p) && off < f.size {
+		index := int(off / int64(f.chunkSize))
+		within := int(off % int64(f.chunkSize))
+		want := min(len(p)-n, f.chunkSize-within, int(f.size-off))
+
+		dst := p[n : n+want]
+		if index < len(f.chunks) && f.chunks[index] != nil {
+			b, err := openChunk(f.chunks[index], &f.id, uint64(index))
+			if err != nil {
+				return n, err
+			}
+			data := b.Bytes()[streamHeaderSize:]
+
+			// Bytes past the end of the stored data are zeroes.
+			copied := 0
+			if within < len(data) {
+				copied = copy(dst, data[within:])
+			}
+			core.Wipe(dst[copied:])
+			b.Destroy()
+		} else {
+			core.Wipe(dst)
+		}
+
+		n += want
+		off += int64(want)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Does not acquire mutex lock.
+func (f *SecretFile) writeAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidOffset
+	}
+
+	n := 0
+	for n < len(p) {
+		index := int(off / int64(f.chunkSize))
+		within := int(off % int64(f.chunkSize))
+		want := min(len(p)-n, f.chunkSize-within)
+
+		for len(f.chunks) <= index {
+			f.chunks = append(f.chunks, nil)
+		}
+
+		// Construct the new contents of the chunk from the old contents and the new data.
+		var old *LockedBuffer
+		stored := 0
+		if f.chunks[index] != nil {
+			b, err := openChunk(f.chunks[index], &f.id, uint64(index))
+			if err != nil {
+				return n, err
+			}
+			old = b
+			stored = b.Size() - streamHeaderSize
+		}
+		c := NewBuffer(max(stored, within+want))
+		if old != nil {
+			c.Copy(old.Bytes()[streamHeaderSize:])
+			old.Destroy()
+		}
+		c.MoveAt(within, p[n:n+want])
+		f.chunks[index] = sealChunk(&f.id, uint64(index), c.Bytes())
+		c.Destroy()
+
+		n += want
+		off += int64(want)
+		if off > f.size {
+			f.size = off
+		}
+	}
+	return n, nil
+}
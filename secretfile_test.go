@@ -0,0 +1,193 @@
+package memguard
+
+import (
+	"bytes"
+	"io"
+	mrand "math/rand"
+	"testing"
+)
+
+// Compile-time interface checks.
+var (
+	_ io.ReadWriteSeeker = (*SecretFile)(nil)
+	_ io.ReaderAt        = (*SecretFile)(nil)
+	_ io.WriterAt        = (*SecretFile)(nil)
+)
+
+func TestSecretFileRandomAccess(t *testing.T) {
+	f := NewSecretFileWithOptions(SecretFileOptions{ChunkSize: 7})
+	var ref []byte
+	rng := mrand.New(mrand.NewSource(1))
+
+	for i := 0; i < 500; i++ {
+		switch rng.Intn(3) {
+		case 0: // write
+			off := rng.Intn(len(ref) + 20)
+			data := make([]byte, rng.Intn(30))
+			ScrambleBytes(data)
+			if len(data) > 0 {
+				if end := off + len(data); end > len(ref) {
+					ref = append(ref, make([]byte, end-len(ref))...)
+				}
+				copy(ref[off:], data)
+			}
+
+			n, err := f.WriteAt(data, int64(off))
+			if err != nil || n != len(data) {
+				t.Fatal("write failed:", n, err)
+			}
+			if !bytes.Equal(data, make([]byte, len(data))) {
+				t.Error("source buffer not wiped")
+			}
+		case 1: // truncate
+			size := rng.Intn(len(ref) + 20)
+			if size < len(ref) {
+				ref = ref[:size]
+			} else {
+				ref = append(ref, make([]byte, size-len(ref))...)
+			}
+			if err := f.Truncate(int64(size)); err != nil {
+				t.Fatal(err)
+			}
+		case 2: // read
+			off := rng.Intn(len(ref) + 1)
+			buf := make([]byte, rng.Intn(30))
+			n, err := f.ReadAt(buf, int64(off))
+			want := min(len(buf), len(ref)-off)
+			if n != want {
+				t.Fatal("read", n, "bytes; want", want)
+			}
+			if n < len(buf) && err != io.EOF {
+				t.Error("expected io.EOF for short read; got", err)
+			}
+			if n == len(buf) && err != nil {
+				t.Error("unexpected error:", err)
+			}
+			if !bytes.Equal(buf[:n], ref[off:off+n]) {
+				t.Fatal("data mismatch at offset", off)
+			}
+		}
+
+		if f.Size() != int64(len(ref)) {
+			t.Fatal("size", f.Size(), "want", len(ref))
+		}
+	}
+
+	// Read the whole thing back.
+	buf := make([]byte, len(ref))
+	if _, err := f.ReadAt(buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, ref) {
+		t.Error("final contents do not match")
+	}
+}
+
+func TestSecretFileSeek(t *testing.T) {
+	f := NewSecretFileWithOptions(SecretFileOptions{ChunkSize: 4})
+
+	if _, err := f.Write([]byte("yellow submarine")); err != nil {
+		t.Fatal(err)
+	}
+	if pos, err := f.Seek(7, io.SeekStart); err != nil || pos != 7 {
+		t.Fatal("seek failed:", pos, err)
+	}
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(f, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "sub" {
+		t.Errorf("unexpected data %q", buf)
+	}
+	if pos, _ := f.Seek(0, io.SeekCurrent); pos != 10 {
+		t.Error("unexpected offset", pos)
+	}
+	if pos, _ := f.Seek(-4, io.SeekEnd); pos != 12 {
+		t.Error("unexpected offset", pos)
+	}
+	rest, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rest) != "rine" {
+		t.Errorf("unexpected data %q", rest)
+	}
+
+	// Seeking past the end and writing leaves a gap of zeroes.
+	if _, err := f.Seek(20, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("!")); err != nil {
+		t.Fatal(err)
+	}
+	buf = make([]byte, 5)
+	if _, err := f.ReadAt(buf, 16); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, []byte{0, 0, 0, 0, '!'}) {
+		t.Errorf("unexpected data %q", buf)
+	}
+
+	// Negative offsets.
+	if _, err := f.Seek(-1, io.SeekStart); err != ErrInvalidOffset {
+		t.Error("expected ErrInvalidOffset; got", err)
+	}
+	if _, err := f.ReadAt(buf, -1); err != ErrInvalidOffset {
+		t.Error("expected ErrInvalidOffset; got", err)
+	}
+	if _, err := f.WriteAt(buf, -1); err != ErrInvalidOffset {
+		t.Error("expected ErrInvalidOffset; got", err)
+	}
+	if err := f.Truncate(-1); err != ErrInvalidOffset {
+		t.Error("expected ErrInvalidOffset; got", err)
+	}
+}
+
+func TestSecretFileTruncate(t *testing.T) {
+	f := NewSecretFileWithOptions(SecretFileOptions{ChunkSize: 4})
+	if _, err := f.Write([]byte("yellow submarine")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Shrinking into the middle of a chunk and growing again should expose zeroes.
+	if err := f.Truncate(6); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(10); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 10)
+	if _, err := f.ReadAt(buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, []byte("yellow\x00\x00\x00\x00")) {
+		t.Errorf("unexpected data %q", buf)
+	}
+	if len(f.chunks) != 2 {
+		t.Error("expected 2 chunks; got", len(f.chunks))
+	}
+
+	if err := f.Truncate(0); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.chunks) != 0 || f.Size() != 0 {
+		t.Error("file should be empty")
+	}
+	if _, err := f.ReadAt(buf, 0); err != io.EOF {
+		t.Error("expected io.EOF; got", err)
+	}
+}
+
+func TestSecretFileTampering(t *testing.T) {
+	f := NewSecretFileWithOptions(SecretFileOptions{ChunkSize: 4})
+	if _, err := f.Write([]byte("yellow submarine")); err != nil {
+		t.Fatal(err)
+	}
+	f.chunks[0], f.chunks[1] = f.chunks[1], f.chunks[0]
+	if _, err := f.ReadAt(make([]byte, 4), 0); err != ErrStreamCorrupt {
+		t.Error("expected ErrStreamCorrupt; got", err)
+	}
+	if _, err := f.WriteAt([]byte("x"), 5); err != ErrStreamCorrupt {
+		t.Error("expected ErrStreamCorrupt; got", err)
+	}
+}
@@ -17,15 +17,54 @@ var (
 
 	// Channel that caught signals are sent to by the runtime
 	listener = make(chan os.Signal, 4)
+
+	// Functions to run before the handler when a signal is caught
+	cleanups = &cleanupList{funcs: make(map[int]func())}
 )
 
+// cleanupList stores functions that restore external state, such as terminal settings, in a thread-safe manner.
+type cleanupList struct {
+	sync.Mutex
+	next  int
+	funcs map[int]func()
+}
+
+// Add registers a function and returns an identifier that can be used to remove it.
+func (l *cleanupList) add(f func()) int {
+	l.Lock()
+	defer l.Unlock()
+
+	l.next++
+	l.funcs[l.next] = f
+	return l.next
+}
+
+// Remove unregisters the function with the given identifier.
+func (l *cleanupList) remove(id int) {
+	l.Lock()
+	defer l.Unlock()
+
+	delete(l.funcs, id)
+}
+
+// Run calls every registered function.
+func (l *cleanupList) run() {
+	l.Lock()
+	defer l.Unlock()
+
+	for _, f := range l.funcs {
+		f()
+	}
+}
+
 /*
 CatchSignal assigns a given function to be run in the event of a signal being received by the process. If no signals are provided all signals will be caught.
 
  1. Signal is caught by the process
- 2. Interrupt handler is executed
- 3. Secure session state is wiped
- 4. Process terminates with exit code 1
+ 2. Any state changed by the library, such as terminal settings, is restored
+ 3. Interrupt handler is executed
+ 4. Secure session state is wiped
+ 5. Process terminates with exit code 1
 
 This function can be called multiple times with the effect that only the last call will have any effect.
 */
@@ -37,6 +76,12 @@ func CatchSignal(f func(os.Signal), signals ...os.Signal) {
 			for {
 				select {
 				case signal := <-listener:
+					// Pick up a handler that was sent just before the signal.
+					select {
+					case handler = <-sigfunc:
+					default:
+					}
+					cleanups.run()
 					handler(signal)
 					core.Exit(1)
 				case handler = <-sigfunc:
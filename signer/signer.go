@@ -0,0 +1,103 @@
+/*
+Package signer implements crypto.Signer for private keys held inside memguard Enclaves.
+
+A signer keeps only the public key in ordinary memory. The private key is decrypted into a LockedBuffer for the duration of each call to Sign and destroyed again before it returns.
+
+The standard library will only sign with keys that live in Go-managed memory, and it derives internal values from them that cannot be wiped from outside. During a signing operation the key is therefore briefly copied to the heap, and that copy is wiped as soon as the signature has been computed.
+*/
+package signer
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"errors"
+	"io"
+
+	"github.com/awnumar/memguard"
+	"github.com/awnumar/memguard/core"
+)
+
+// ErrInvalidKey is returned when an Enclave does not contain a valid private key.
+var ErrInvalidKey = errors.New("<memguard::signer::ErrInvalidKey> enclave does not contain a valid private key")
+
+// ed25519Signer implements crypto.Signer using an Ed25519 seed sealed inside an Enclave.
+type ed25519Signer struct {
+	seed *memguard.Enclave
+	pub  ed25519.PublicKey
+}
+
+/*
+NewEd25519 returns a crypto.Signer for the Ed25519 private key held inside an Enclave. The Enclave may contain either a 32 byte seed, as returned by GenerateEd25519, or a 64 byte private key in the format used by crypto/ed25519. In the latter case the public half is checked against the seed.
+
+The signer supports both pure Ed25519 and Ed25519ph, selected through the options passed to Sign as with ed25519.PrivateKey.
+*/
+func NewEd25519(e *memguard.Enclave) (crypto.Signer, error) {
+	b, err := e.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer b.Destroy()
+
+	switch b.Size() {
+	case ed25519.SeedSize:
+		pub := publicKey(b.Bytes())
+		return &ed25519Signer{seed: e, pub: pub}, nil
+
+	case ed25519.PrivateKeySize:
+		pub := publicKey(b.Bytes()[:ed25519.SeedSize])
+		if !core.Equal(pub, b.Bytes()[ed25519.SeedSize:]) {
+			return nil, ErrInvalidKey
+		}
+
+		// Only keep the seed.
+		seed := memguard.NewBuffer(ed25519.SeedSize)
+		seed.Copy(b.Bytes()[:ed25519.SeedSize])
+		return &ed25519Signer{seed: seed.Seal(), pub: pub}, nil
+	}
+
+	return nil, ErrInvalidKey
+}
+
+/*
+GenerateEd25519 generates a new Ed25519 key pair. The 32 byte seed is generated inside a LockedBuffer and returned sealed inside an Enclave, ready to be passed to NewEd25519.
+*/
+func GenerateEd25519() (ed25519.PublicKey, *memguard.Enclave) {
+	seed := memguard.NewBufferRandom(ed25519.SeedSize)
+	pub := publicKey(seed.Bytes())
+	return pub, seed.Seal()
+}
+
+// Public returns the public key corresponding to the private key.
+func (s *ed25519Signer) Public() crypto.PublicKey {
+	return s.pub
+}
+
+/*
+Sign decrypts the private key, signs the message with it and wipes all copies of the key that it can reach. If opts.HashFunc() is crypto.SHA512 the message must be a SHA-512 digest and Ed25519ph is used.
+*/
+func (s *ed25519Signer) Sign(rand io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	seed, err := s.seed.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer seed.Destroy()
+
+	// Lay out the private key in the form crypto/ed25519 expects: seed || public key.
+	// It refuses to operate on memory that is not managed by the Go runtime.
+	priv := make(ed25519.PrivateKey, ed25519.PrivateKeySize)
+	defer core.Wipe(priv)
+	copy(priv, seed.Bytes())
+	copy(priv[ed25519.SeedSize:], s.pub)
+
+	return priv.Sign(rand, message, opts)
+}
+
+// Derives the public key for a seed, wiping the intermediate private key.
+func publicKey(seed []byte) ed25519.PublicKey {
+	priv := ed25519.NewKeyFromSeed(seed)
+	defer core.Wipe(priv)
+
+	pub := make(ed25519.PublicKey, ed25519.PublicKeySize)
+	copy(pub, priv[ed25519.SeedSize:])
+	return pub
+}
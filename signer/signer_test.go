@@ -0,0 +1,113 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"testing"
+
+	"github.com/awnumar/memguard"
+)
+
+func TestGenerateEd25519(t *testing.T) {
+	pub, priv := GenerateEd25519()
+	if len(pub) != ed25519.PublicKeySize {
+		t.Error("public key has incorrect size")
+	}
+	if priv.Size() != ed25519.SeedSize {
+		t.Error("private key has incorrect size")
+	}
+
+	b, err := priv.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Destroy()
+	if !ed25519.NewKeyFromSeed(b.Bytes()).Public().(ed25519.PublicKey).Equal(pub) {
+		t.Error("public key does not match seed")
+	}
+}
+
+func TestNewEd25519(t *testing.T) {
+	pub, priv := GenerateEd25519()
+	s, err := NewEd25519(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Public().(ed25519.PublicKey).Equal(pub) {
+		t.Error("public key does not match")
+	}
+
+	msg := []byte("yellow submarine")
+	sig, err := s.Sign(nil, msg, crypto.Hash(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(pub, msg, sig) {
+		t.Error("signature does not verify")
+	}
+
+	// Ed25519ph.
+	digest := sha512.Sum512(msg)
+	opts := &ed25519.Options{Hash: crypto.SHA512}
+	sig, err = s.Sign(nil, digest[:], opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ed25519.VerifyWithOptions(pub, digest[:], sig, opts); err != nil {
+		t.Error("signature does not verify:", err)
+	}
+
+	// Unsupported hash.
+	if _, err := s.Sign(nil, msg, crypto.SHA256); err == nil {
+		t.Error("expected error for unsupported hash")
+	}
+}
+
+func TestNewEd25519FullKey(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := make([]byte, len(priv))
+	copy(ref, priv)
+
+	s, err := NewEd25519(memguard.NewEnclave(priv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Public().(ed25519.PublicKey).Equal(pub) {
+		t.Error("public key does not match")
+	}
+	msg := []byte("yellow submarine")
+	sig, err := s.Sign(nil, msg, crypto.Hash(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(pub, msg, sig) {
+		t.Error("signature does not verify")
+	}
+
+	// A key whose public half does not match its seed.
+	ref[len(ref)-1] ^= 1
+	if _, err := NewEd25519(memguard.NewEnclave(ref)); err != ErrInvalidKey {
+		t.Error("expected ErrInvalidKey; got", err)
+	}
+
+	// A key of the wrong size.
+	if _, err := NewEd25519(memguard.NewEnclaveRandom(16)); err != ErrInvalidKey {
+		t.Error("expected ErrInvalidKey; got", err)
+	}
+}
+
+func TestSignAfterPurge(t *testing.T) {
+	_, priv := GenerateEd25519()
+	s, err := NewEd25519(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memguard.Purge()
+	if _, err := s.Sign(nil, []byte("yellow submarine"), crypto.Hash(0)); err == nil {
+		t.Error("expected error signing after purge")
+	}
+}
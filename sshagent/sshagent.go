@@ -0,0 +1,427 @@
+/*
+Package sshagent implements an SSH agent keyring that keeps its private keys sealed inside memguard Enclaves.
+
+The keyring returned by NewKeyring is a drop-in replacement for the one returned by agent.NewKeyring and may be served with agent.ServeAgent. Each key that is added is converted to its PKCS #8 encoding and sealed, and is only decrypted and parsed for the duration of a signing operation.
+
+Locking the agent goes further: every key is re-encrypted under a key derived from the passphrase with Argon2id and its Enclave is wiped, so that the keys cannot be recovered by the process at all until the agent is unlocked again.
+
+The sealed keys are also wiped when they are removed, replaced or expire.
+*/
+package sshagent
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/awnumar/memguard"
+	"github.com/awnumar/memguard/core"
+	"github.com/awnumar/memguard/internal/privkey"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ErrLocked is returned when an operation is attempted on a locked agent.
+var ErrLocked = errors.New("<memguard::sshagent::ErrLocked> agent is locked")
+
+// ErrNotLocked is returned by Unlock when the agent is not locked.
+var ErrNotLocked = errors.New("<memguard::sshagent::ErrNotLocked> agent is not locked")
+
+// ErrIncorrectPassphrase is returned by Unlock when the passphrase does not match the one given to Lock.
+var ErrIncorrectPassphrase = errors.New("<memguard::sshagent::ErrIncorrectPassphrase> incorrect passphrase")
+
+// ErrKeyNotFound is returned when the agent does not hold the requested key.
+var ErrKeyNotFound = errors.New("<memguard::sshagent::ErrKeyNotFound> key not found")
+
+// ErrUnsupportedKey is returned when adding a key of a type that cannot be stored, such as DSA.
+var ErrUnsupportedKey = errors.New("<memguard::sshagent::ErrUnsupportedKey> unsupported private key type")
+
+// Argon2id parameters used to derive the lock key from a passphrase.
+const (
+	argonTime    = 1
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+	saltSize     = 16
+)
+
+// Encrypted under the lock key so that Unlock can check the passphrase even if the agent holds no keys.
+var lockCheck = []byte("memguard/sshagent/lock")
+
+type sealedKey struct {
+	der     *memguard.Enclave // PKCS #8 encoding, nil while locked
+	locked  []byte            // der encrypted under the lock key while locked
+	pub     ssh.PublicKey     // public key, or certificate if one was given
+	cert    *ssh.Certificate
+	comment string
+	expire  *time.Time
+}
+
+type keyring struct {
+	mu   sync.Mutex
+	keys []*sealedKey
+
+	locked bool
+	salt   []byte
+	check  []byte
+}
+
+/*
+NewKeyring returns an agent.ExtendedAgent that holds its keys sealed inside Enclaves. It is safe for concurrent use by multiple goroutines.
+*/
+func NewKeyring() agent.ExtendedAgent {
+	return &keyring{}
+}
+
+/*
+Add seals a private key and adds it to the keyring, replacing any key with the same public key. RSA, ECDSA and Ed25519 keys are supported. The secret values of the private key passed in are overwritten once it has been sealed. Constraints other than LifetimeSecs are ignored.
+*/
+func (r *keyring) Add(key agent.AddedKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.locked {
+		return ErrLocked
+	}
+
+	priv := key.PrivateKey
+	if k, ok := priv.(*ed25519.PrivateKey); ok {
+		priv = *k
+	}
+	switch priv.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+	default:
+		return ErrUnsupportedKey
+	}
+
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return err
+	}
+	k := &sealedKey{pub: signer.PublicKey(), comment: key.Comment}
+	if key.Certificate != nil {
+		if _, err := ssh.NewCertSigner(key.Certificate, signer); err != nil {
+			return err
+		}
+		k.pub, k.cert = key.Certificate, key.Certificate
+	}
+	if key.LifetimeSecs > 0 {
+		t := time.Now().Add(time.Duration(key.LifetimeSecs) * time.Second)
+		k.expire = &t
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	k.der = memguard.NewEnclave(der) // wipes der
+	privkey.Wipe(priv)
+
+	// If we already have a key with the same public key, replace it.
+	want := k.pub.Marshal()
+	for i, existing := range r.keys {
+		if bytes.Equal(existing.pub.Marshal(), want) {
+			existing.wipe()
+			r.keys[i] = k
+			return nil
+		}
+	}
+	r.keys = append(r.keys, k)
+	return nil
+}
+
+// Remove removes and wipes all keys with the given public key.
+func (r *keyring) Remove(key ssh.PublicKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.locked {
+		return ErrLocked
+	}
+
+	return r.removeLocked(key.Marshal())
+}
+
+// RemoveAll removes and wipes all keys.
+func (r *keyring) RemoveAll() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.locked {
+		return ErrLocked
+	}
+
+	for i, k := range r.keys {
+		k.wipe()
+		r.keys[i] = nil
+	}
+	r.keys = nil
+	return nil
+}
+
+// Removes and wipes the keys with the given public key. The caller must be holding the keyring mutex.
+func (r *keyring) removeLocked(want []byte) error {
+	found := false
+	for i := 0; i < len(r.keys); {
+		if bytes.Equal(r.keys[i].pub.Marshal(), want) {
+			found = true
+			r.keys[i].wipe()
+			r.keys[i] = r.keys[len(r.keys)-1]
+			r.keys[len(r.keys)-1] = nil
+			r.keys = r.keys[:len(r.keys)-1]
+			continue
+		}
+		i++
+	}
+
+	if !found {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+// Removes keys whose lifetime has passed. The caller must be holding the keyring mutex.
+func (r *keyring) expireKeysLocked() {
+	now := time.Now()
+	for i := 0; i < len(r.keys); {
+		if k := r.keys[i]; k.expire != nil && now.After(*k.expire) {
+			r.removeLocked(k.pub.Marshal())
+			continue
+		}
+		i++
+	}
+}
+
+/*
+Lock locks the agent. Every key is decrypted, re-encrypted under a key derived from the passphrase and its Enclave is wiped. While locked, List returns no keys and all other operations fail.
+*/
+func (r *keyring) Lock(passphrase []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.locked {
+		return ErrLocked
+	}
+
+	salt := make([]byte, saltSize)
+	if err := core.Scramble(salt); err != nil {
+		return err
+	}
+	lockKey := deriveKey(passphrase, salt)
+	defer lockKey.Destroy()
+
+	check, err := core.Encrypt(lockCheck, lockKey.Bytes())
+	if err != nil {
+		return err
+	}
+
+	locked := make([][]byte, len(r.keys))
+	for i, k := range r.keys {
+		der, err := k.der.Open()
+		if err != nil {
+			return err
+		}
+		locked[i], err = core.Encrypt(der.Bytes(), lockKey.Bytes())
+		der.Destroy()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Only commit once every key has been locked.
+	for i, k := range r.keys {
+		core.WipeEnclave(k.der.Enclave)
+		k.der, k.locked = nil, locked[i]
+	}
+	r.locked, r.salt, r.check = true, salt, check
+	return nil
+}
+
+/*
+Unlock undoes the effect of Lock, decrypting each key with the passphrase and sealing it back into an Enclave.
+*/
+func (r *keyring) Unlock(passphrase []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.locked {
+		return ErrNotLocked
+	}
+
+	lockKey := deriveKey(passphrase, r.salt)
+	defer lockKey.Destroy()
+
+	check := make([]byte, len(r.check)-core.Overhead)
+	if _, err := core.Decrypt(r.check, lockKey.Bytes(), check); err != nil {
+		return ErrIncorrectPassphrase
+	}
+
+	unlocked := make([]*memguard.Enclave, len(r.keys))
+	for i, k := range r.keys {
+		der := memguard.NewBuffer(len(k.locked) - core.Overhead)
+		if _, err := core.Decrypt(k.locked, lockKey.Bytes(), der.Bytes()); err != nil {
+			der.Destroy()
+			return ErrIncorrectPassphrase
+		}
+		unlocked[i] = der.Seal()
+	}
+
+	for i, k := range r.keys {
+		core.Wipe(k.locked)
+		k.der, k.locked = unlocked[i], nil
+	}
+	r.locked, r.salt, r.check = false, nil, nil
+	return nil
+}
+
+// List returns the identities known to the agent.
+func (r *keyring) List() ([]*agent.Key, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.locked {
+		// section 2.7: locked agents return empty.
+		return nil, nil
+	}
+
+	r.expireKeysLocked()
+	var ids []*agent.Key
+	for _, k := range r.keys {
+		ids = append(ids, &agent.Key{
+			Format:  k.pub.Type(),
+			Blob:    k.pub.Marshal(),
+			Comment: k.comment,
+		})
+	}
+	return ids, nil
+}
+
+// Sign returns a signature for the data using the default algorithm of the key.
+func (r *keyring) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	return r.SignWithFlags(key, data, 0)
+}
+
+// SignWithFlags returns a signature for the data, using the algorithm selected by the flags for RSA keys.
+func (r *keyring) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	var algorithm string
+	switch flags {
+	case 0:
+	case agent.SignatureFlagRsaSha256:
+		algorithm = ssh.KeyAlgoRSASHA256
+	case agent.SignatureFlagRsaSha512:
+		algorithm = ssh.KeyAlgoRSASHA512
+	default:
+		return nil, fmt.Errorf("<memguard::sshagent> unsupported signature flags: %d", flags)
+	}
+	return r.sign(key, rand.Reader, data, algorithm)
+}
+
+// Opens the key matching the given public key and signs the data with it. An empty algorithm selects the default.
+func (r *keyring) sign(key ssh.PublicKey, rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.locked {
+		return nil, ErrLocked
+	}
+
+	r.expireKeysLocked()
+	want := key.Marshal()
+	for _, k := range r.keys {
+		if bytes.Equal(k.pub.Marshal(), want) {
+			return k.sign(rand, data, algorithm)
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+// Wipes the sealed key, whether it is held in its Enclave or encrypted under the lock key.
+func (k *sealedKey) wipe() {
+	if k.der != nil {
+		core.WipeEnclave(k.der.Enclave)
+	}
+	core.Wipe(k.locked)
+	k.der, k.locked = nil, nil
+}
+
+// Decrypts and parses the private key, signs the data with it and then wipes the parsed key.
+func (k *sealedKey) sign(rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	der, err := k.der.Open()
+	if err != nil {
+		return nil, err
+	}
+	priv, err := x509.ParsePKCS8PrivateKey(der.Bytes())
+	der.Destroy()
+	if err != nil {
+		return nil, err
+	}
+	defer privkey.Wipe(priv)
+
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	if k.cert != nil {
+		if signer, err = ssh.NewCertSigner(k.cert, signer); err != nil {
+			return nil, err
+		}
+	}
+
+	if algorithm == "" {
+		return signer.Sign(rand, data)
+	}
+	as, ok := signer.(ssh.AlgorithmSigner)
+	if !ok {
+		return nil, fmt.Errorf("<memguard::sshagent> key does not support signature algorithm %s", algorithm)
+	}
+	return as.SignWithAlgorithm(rand, data, algorithm)
+}
+
+/*
+Signers returns signers for all the known keys. The signers do not hold any key material themselves: each signature is made by the keyring, so they stop working once their key is removed or the agent is locked.
+*/
+func (r *keyring) Signers() ([]ssh.Signer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.locked {
+		return nil, ErrLocked
+	}
+
+	r.expireKeysLocked()
+	s := make([]ssh.Signer, 0, len(r.keys))
+	for _, k := range r.keys {
+		s = append(s, &keyringSigner{r: r, pub: k.pub})
+	}
+	return s, nil
+}
+
+// Extension is not supported by this keyring.
+func (r *keyring) Extension(extensionType string, contents []byte) ([]byte, error) {
+	return nil, agent.ErrExtensionUnsupported
+}
+
+// keyringSigner implements ssh.AlgorithmSigner by asking the keyring to sign with a given key.
+type keyringSigner struct {
+	r   *keyring
+	pub ssh.PublicKey
+}
+
+func (s *keyringSigner) PublicKey() ssh.PublicKey {
+	return s.pub
+}
+
+func (s *keyringSigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	return s.r.sign(s.pub, rand, data, "")
+}
+
+func (s *keyringSigner) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	return s.r.sign(s.pub, rand, data, algorithm)
+}
+
+// Derives a lock key from a passphrase into a LockedBuffer.
+func deriveKey(passphrase, salt []byte) *memguard.LockedBuffer {
+	b := memguard.NewBuffer(32)
+	b.Move(argon2.IDKey(passphrase, salt, argonTime, argonMemory, argonThreads, 32))
+	return b
+}
@@ -0,0 +1,299 @@
+package sshagent
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/awnumar/memguard"
+	"github.com/awnumar/memguard/core"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Serves a new keyring over an in-memory connection and returns a client for it.
+func newClient(t *testing.T) (agent.ExtendedAgent, *keyring) {
+	r := NewKeyring().(*keyring)
+	c1, c2 := net.Pipe()
+	go agent.ServeAgent(r, c2)
+	t.Cleanup(func() {
+		c1.Close()
+		c2.Close()
+	})
+	return agent.NewClient(c1), r
+}
+
+// Generates one key of each supported type.
+func generateKeys(t *testing.T) []crypto.Signer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []crypto.Signer{rsaKey, ecKey, edKey}
+}
+
+func publicKey(t *testing.T, key crypto.Signer) ssh.PublicKey {
+	pub, err := ssh.NewPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
+
+func TestAgent(t *testing.T) {
+	client, r := newClient(t)
+	keys := generateKeys(t)
+
+	for _, key := range keys {
+		if err := client.Add(agent.AddedKey{PrivateKey: key, Comment: "yellow submarine"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := client.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != len(keys) {
+		t.Fatal("expected", len(keys), "keys; got", len(list))
+	}
+	for i, key := range keys {
+		if !bytes.Equal(list[i].Blob, publicKey(t, key).Marshal()) || list[i].Comment != "yellow submarine" {
+			t.Error("unexpected key in list:", list[i])
+		}
+	}
+
+	// The keys should be stored sealed.
+	for _, k := range r.keys {
+		if k.der == nil || k.der.Size() == 0 {
+			t.Error("key is not sealed")
+		}
+	}
+
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	for _, key := range keys {
+		pub := publicKey(t, key)
+		sig, err := client.Sign(pub, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pub.Verify(data, sig); err != nil {
+			t.Error("signature does not verify:", err)
+		}
+	}
+
+	// RSA keys support SHA-2 signatures.
+	pub := publicKey(t, keys[0])
+	sig, err := client.SignWithFlags(pub, data, agent.SignatureFlagRsaSha512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig.Format != ssh.KeyAlgoRSASHA512 {
+		t.Error("unexpected signature format", sig.Format)
+	}
+	if err := pub.Verify(data, sig); err != nil {
+		t.Error("signature does not verify:", err)
+	}
+
+	// Signers obtained directly from the keyring.
+	signers, err := r.Signers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range signers {
+		sig, err := s.Sign(rand.Reader, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.PublicKey().Verify(data, sig); err != nil {
+			t.Error("signature does not verify:", err)
+		}
+	}
+
+	// Remove one key, then the rest. Their Enclaves are wiped.
+	sealed := make([]*memguard.Enclave, len(r.keys))
+	for i, k := range r.keys {
+		sealed[i] = k.der
+	}
+	if err := client.Remove(publicKey(t, keys[1])); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Sign(publicKey(t, keys[1]), data); err == nil {
+		t.Error("expected error signing with removed key")
+	}
+	if list, _ := client.List(); len(list) != len(keys)-1 {
+		t.Error("expected", len(keys)-1, "keys; got", len(list))
+	}
+	if err := client.RemoveAll(); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := client.List(); len(list) != 0 {
+		t.Error("expected no keys; got", len(list))
+	}
+	if _, err := signers[0].Sign(rand.Reader, data); err != ErrKeyNotFound {
+		t.Error("expected ErrKeyNotFound; got", err)
+	}
+	for _, e := range sealed {
+		if _, err := e.Open(); err != core.ErrDecryptionFailed {
+			t.Error("expected removed key to be wiped; got", err)
+		}
+	}
+}
+
+func TestLock(t *testing.T) {
+	client, r := newClient(t)
+	keys := generateKeys(t)
+	for _, key := range keys {
+		if err := client.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sealed := r.keys[0].der
+	if err := client.Lock([]byte("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range r.keys {
+		if k.der != nil || k.locked == nil {
+			t.Error("key was not locked")
+		}
+	}
+	if _, err := sealed.Open(); err != core.ErrDecryptionFailed {
+		t.Error("expected Enclave to be wiped; got", err)
+	}
+
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	if list, err := client.List(); err != nil || len(list) != 0 {
+		t.Error("locked agent should list no keys:", err)
+	}
+	if _, err := client.Sign(publicKey(t, keys[0]), data); err == nil {
+		t.Error("expected error signing while locked")
+	}
+	if err := client.Add(agent.AddedKey{PrivateKey: keys[0]}); err == nil {
+		t.Error("expected error adding while locked")
+	}
+	if err := client.Lock([]byte("hunter2")); err == nil {
+		t.Error("expected error locking twice")
+	}
+	if err := r.Unlock([]byte("hunter3")); err != ErrIncorrectPassphrase {
+		t.Error("expected ErrIncorrectPassphrase; got", err)
+	}
+
+	if err := client.Unlock([]byte("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Unlock([]byte("hunter2")); err != ErrNotLocked {
+		t.Error("expected ErrNotLocked; got", err)
+	}
+	for _, key := range keys {
+		pub := publicKey(t, key)
+		sig, err := client.Sign(pub, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pub.Verify(data, sig); err != nil {
+			t.Error("signature does not verify:", err)
+		}
+	}
+
+	// An empty agent can still check the passphrase.
+	client, _ = newClient(t)
+	if err := client.Lock([]byte("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Unlock([]byte("hunter3")); err == nil {
+		t.Error("expected error unlocking with the wrong passphrase")
+	}
+	if err := client.Unlock([]byte("hunter2")); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestLifetime(t *testing.T) {
+	client, r := newClient(t)
+	keys := generateKeys(t)
+	if err := client.Add(agent.AddedKey{PrivateKey: keys[2], LifetimeSecs: 60}); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := client.List(); len(list) != 1 {
+		t.Fatal("expected one key; got", len(list))
+	}
+
+	// Pretend the lifetime has passed.
+	r.mu.Lock()
+	past := time.Now().Add(-time.Second)
+	r.keys[0].expire = &past
+	sealed := r.keys[0].der
+	r.mu.Unlock()
+
+	if list, _ := client.List(); len(list) != 0 {
+		t.Error("expected expired key to be removed")
+	}
+	if _, err := sealed.Open(); err != core.ErrDecryptionFailed {
+		t.Error("expected expired key to be wiped; got", err)
+	}
+}
+
+func TestCertificate(t *testing.T) {
+	client, _ := newClient(t)
+	keys := generateKeys(t)
+
+	signer, err := ssh.NewSignerFromKey(keys[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &ssh.Certificate{
+		Key:             publicKey(t, keys[2]),
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"user"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, signer); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Add(agent.AddedKey{PrivateKey: keys[2], Certificate: cert}); err != nil {
+		t.Fatal(err)
+	}
+	list, err := client.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || !bytes.Equal(list[0].Blob, cert.Marshal()) {
+		t.Fatal("certificate not listed")
+	}
+
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	sig, err := client.Sign(cert, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cert.Verify(data, sig); err != nil {
+		t.Error("signature does not verify:", err)
+	}
+}
+
+func TestUnsupportedKey(t *testing.T) {
+	r := NewKeyring()
+	if err := r.Add(agent.AddedKey{PrivateKey: "not a key"}); err != ErrUnsupportedKey {
+		t.Error("expected ErrUnsupportedKey; got", err)
+	}
+	if _, err := r.Extension("foo@example.com", nil); err != agent.ErrExtensionUnsupported {
+		t.Error("expected ErrExtensionUnsupported; got", err)
+	}
+}
@@ -2,19 +2,38 @@ package memguard
 
 import (
 	"container/list"
+	"encoding/binary"
+	"errors"
 	"io"
 	"os"
+	"runtime"
 	"sync"
+	"sync/atomic"
 
 	"github.com/awnumar/memguard/core"
 )
 
 var (
-	// StreamChunkSize is the maximum amount of data that is locked into memory at a time.
+	// StreamChunkSize is the default maximum amount of data that is locked into memory at a time.
+	// It is read when a Stream is created, so changing it only affects streams created afterwards.
 	// If you get error allocating memory, increase your system's mlock limits.
 	// Use 'ulimit -l' to see mlock limit on unix systems.
-	StreamChunkSize = c
-	c               = os.Getpagesize() * 4
+	StreamChunkSize  = defaultChunkSize
+	defaultChunkSize = os.Getpagesize() * 4
+)
+
+var (
+	// ErrStreamCorrupt is returned when the chunks of a Stream have been reordered, duplicated, removed or swapped with those of another Stream.
+	ErrStreamCorrupt = errors.New("<memguard::ErrStreamCorrupt> stream chunks are out of sequence")
+
+	// ErrStreamClosed is returned when attempting to write to a Stream that has been closed.
+	ErrStreamClosed = errors.New("<memguard::ErrStreamClosed> stream has been closed")
+)
+
+// Each chunk is prefixed inside its Enclave with the ID of its stream and its position within it.
+const (
+	streamIDSize     = 16
+	streamHeaderSize = streamIDSize + 8
 )
 
 type queue struct {
@@ -46,71 +65,474 @@ func (q *queue) pop() *Enclave {
 Stream is an in-memory encrypted container implementing the reader and writer interfaces.
 
 It is most useful when you need to store lots of data in memory and are able to work on it in chunks.
+
+Writes are coalesced in a LockedBuffer until a full chunk has accumulated, so that many small writes do not each produce a chunk of their own. The buffer is sealed early if its contents are needed before then, such as when the Stream is read from or cloned.
+
+Every chunk is sealed together with a random identifier for the stream and a counter giving its position, in the manner of the STREAM construction. Reading a chunk that belongs to another stream, or that is out of sequence because chunks were reordered, duplicated or removed, fails with ErrStreamCorrupt.
 */
 type Stream struct {
 	sync.Mutex
 	*queue
+
+	chunkSize   int
+	parallelism int
+
+	ident   streamIdentity
+	written uint64 // counter of the next chunk to be written
+	read    uint64 // counter of the next chunk expected to be read
+
+	pending    *LockedBuffer // chunk being filled by writes, with space for the header; nil if empty
+	pendingLen int           // number of bytes of data in pending
+
+	closed bool
+}
+
+// streamIdentity records the identifiers that the chunks of a stream are sealed with. New chunks are sealed with id, but a cloned stream shares the chunks that were written before it was cloned, and those keep the identifiers they were sealed with.
+type streamIdentity struct {
+	id      [streamIDSize]byte
+	parents []streamEpoch
+}
+
+// streamEpoch covers the chunks inherited from the stream that a clone was made from.
+type streamEpoch struct {
+	id  [streamIDSize]byte
+	end uint64 // chunks with a counter below end were sealed with id
+}
+
+// Returns a new identity with a random identifier.
+func newStreamIdentity() streamIdentity {
+	var i streamIdentity
+	if err := core.Scramble(i.id[:]); err != nil {
+		core.Panic(err)
+	}
+	return i
+}
+
+// Returns the identifier that the chunk with the given counter was sealed with.
+func (i *streamIdentity) idFor(counter uint64) *[streamIDSize]byte {
+	for j := range i.parents {
+		if counter < i.parents[j].end {
+			return &i.parents[j].id
+		}
+	}
+	return &i.id
+}
+
+// Returns an identity with a new identifier for chunks from written onwards, inheriting the identifiers of earlier chunks. Epochs that only cover chunks before read are dropped.
+func (i *streamIdentity) fork(read, written uint64) streamIdentity {
+	f := newStreamIdentity()
+	for _, p := range i.parents {
+		if p.end > read {
+			f.parents = append(f.parents, p)
+		}
+	}
+	if written > read && (len(f.parents) == 0 || f.parents[len(f.parents)-1].end < written) {
+		f.parents = append(f.parents, streamEpoch{id: i.id, end: written})
+	}
+	return f
+}
+
+/*
+StreamOptions configures a Stream created by NewStreamWithOptions.
+*/
+type StreamOptions struct {
+	// ChunkSize is the maximum size of each encrypted chunk, and so the maximum amount of data that is locked into memory at a time. Larger chunks spend less on encryption overhead but lock more memory. If zero, the value of StreamChunkSize is used.
+	ChunkSize int
+
+	// Parallelism is the maximum number of chunks that are encrypted or decrypted concurrently when a Stream is written to or read from in bulk, by Write, WriteTo and Flush. Each chunk being worked on is held in its own LockedBuffer. If zero, the value of runtime.GOMAXPROCS is used. A value of one disables parallelism.
+	Parallelism int
 }
 
-// NewStream initialises a new empty Stream object.
+// NewStream initialises a new empty Stream object, with chunks of StreamChunkSize bytes.
 func NewStream() *Stream {
-	return &Stream{queue: &queue{List: list.New()}}
+	return NewStreamWithOptions(StreamOptions{})
+}
+
+// NewStreamWithOptions initialises a new empty Stream object with the given options.
+func NewStreamWithOptions(opts StreamOptions) *Stream {
+	size := opts.ChunkSize
+	if size < 1 {
+		size = StreamChunkSize
+	}
+	if size < 1 {
+		size = defaultChunkSize
+	}
+	parallelism := opts.Parallelism
+	if parallelism < 1 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	return &Stream{queue: &queue{List: list.New()}, chunkSize: size, parallelism: parallelism, ident: newStreamIdentity()}
+}
+
+// ChunkSize returns the maximum size of the chunks that data written to a Stream is broken into.
+func (s *Stream) ChunkSize() int {
+	return s.chunkSize
 }
 
 /*
 Write encrypts and writes some given data to a Stream object.
 
-The data is broken down into chunks and added to the stream in order. The last thing to be written to the stream is the last thing that will be read back.
+The data is broken down into chunks and added to the stream in order. The last thing to be written to the stream is the last thing that will be read back. The data is wiped after it has been written. When the data spans multiple chunks, they are encrypted in parallel.
+
+If the Stream has been closed, nothing is written, the data is wiped and ErrStreamClosed is returned. If data that was waiting to be sealed has been destroyed by a call to Purge, nothing is written and core.ErrBufferExpired is returned, leaving the data untouched so that it can be written again.
 */
 func (s *Stream) Write(data []byte) (int, error) {
 	s.Lock()
 	defer s.Unlock()
 
-	for i := 0; i < len(data); i += c {
-		if i+c > len(data) {
-			s.join(NewEnclave(data[len(data)-(len(data)%c):]))
-		} else {
-			s.join(NewEnclave(data[i : i+c]))
+	if s.closed {
+		core.Wipe(data)
+		return 0, ErrStreamClosed
+	}
+	if err := s.checkPending(); err != nil {
+		return 0, err
+	}
+	// Top up the write buffer if it already holds some data.
+	i := 0
+	if s.pending != nil {
+		i = min(len(data), s.chunkSize-s.pendingLen)
+		s.pending.MoveAt(streamHeaderSize+s.pendingLen, data[:i])
+		s.pendingLen += i
+		if s.pendingLen == s.chunkSize {
+			s.sealPending() // cannot fail, since we checked the buffer
 		}
 	}
+
+	// Seal any full chunks directly from the data.
+	if full := (len(data) - i) / s.chunkSize; full > 0 {
+		chunks := make([]*Enclave, full)
+		parallel(s.parallelism, full, func(j int) {
+			offset := i + j*s.chunkSize
+			chunks[j] = s.seal(s.written+uint64(j), data[offset:offset+s.chunkSize])
+		})
+		for _, c := range chunks {
+			s.join(c)
+		}
+		s.written += uint64(full)
+		i += full * s.chunkSize
+	}
+
+	// Hold on to whatever is left until there is enough for a full chunk.
+	if i < len(data) {
+		s.pending = NewBuffer(streamHeaderSize + s.chunkSize)
+		s.pending.MoveAt(streamHeaderSize, data[i:])
+		s.pendingLen = len(data) - i
+	}
 	return len(data), nil
 }
 
+// Runs f for every index in [0, n) on at most workers goroutines, returning once all calls have completed.
+func parallel(workers, n int, f func(i int)) {
+	workers = min(workers, n)
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
+	}
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < n; i = int(next.Add(1) - 1) {
+				f(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Seals the data waiting in the write buffer into a chunk at the back of the queue. Does not acquire mutex lock.
+func (s *Stream) sealPending() error {
+	if s.pending == nil {
+		return nil
+	}
+	if err := s.checkPending(); err != nil {
+		return err
+	}
+	if s.pendingLen == s.chunkSize {
+		s.join(sealChunkBuffer(s.pending, s.ident.idFor(s.written), s.written))
+	} else {
+		s.join(s.seal(s.written, s.pending.Bytes()[streamHeaderSize:streamHeaderSize+s.pendingLen]))
+		s.pending.Destroy()
+	}
+	s.written++
+	s.pending, s.pendingLen = nil, 0
+	return nil
+}
+
+// Returns core.ErrBufferExpired if the write buffer has been destroyed by a call to Purge, since the data in it has been lost. The buffer is dropped, so the loss is only reported once and the Stream can be used again. Does not acquire mutex lock.
+func (s *Stream) checkPending() error {
+	if s.pendingLost() {
+		s.pending, s.pendingLen = nil, 0 // nothing was sealed, so no counter was used
+		return core.ErrBufferExpired
+	}
+	return nil
+}
+
+// Reports whether the write buffer has been destroyed by a call to Purge. Does not acquire mutex lock.
+func (s *Stream) pendingLost() bool {
+	return s.pending != nil && !s.pending.IsAlive()
+}
+
+// Destroys the contents of the write buffer. Does not acquire mutex lock.
+func (s *Stream) discardPending() {
+	if s.pending != nil {
+		s.pending.Destroy()
+	}
+	s.pending, s.pendingLen = nil, 0
+}
+
+/*
+ReadFrom reads data from r until io.EOF and writes it to a Stream, implementing io.ReaderFrom. Each chunk is read directly into a guarded allocation and sealed from there, so io.Copy never has to pass the data through an unprotected intermediate buffer.
+
+Reads are accumulated until a chunk is full and are coalesced with earlier writes in the same way as Write, so the chunks are as large as they would be had the data been written in a single call. It returns the number of bytes read. Any error other than io.EOF is returned, and the data read before it is kept. If the Stream is closed, ErrStreamClosed is returned and the data that could not be written is wiped. If data written earlier was destroyed by a call to Purge before it could be sealed, core.ErrBufferExpired is returned after the data that was just read has been written.
+*/
+func (s *Stream) ReadFrom(r io.Reader) (int64, error) {
+	s.Lock()
+	closed := s.closed
+	s.Unlock()
+	if closed {
+		return 0, ErrStreamClosed
+	}
+
+	var total int64
+	for {
+		// Read directly into the guarded allocation, leaving space for the header.
+		b := NewBuffer(streamHeaderSize + s.chunkSize)
+		data := b.Bytes()[streamHeaderSize:]
+		var n int
+		var err error
+		for n < len(data) && err == nil {
+			var m int
+			m, err = r.Read(data[n:])
+			n += m
+		}
+
+		if n > 0 {
+			s.Lock()
+			if s.closed {
+				s.Unlock()
+				b.Destroy()
+				return total, ErrStreamClosed
+			}
+			lost := s.checkPending() // the data just read is kept regardless
+			s.fill(b, n)
+			s.Unlock()
+			total += int64(n)
+			if lost != nil {
+				return total, lost
+			}
+		} else {
+			b.Destroy()
+		}
+
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// Adds n bytes read into a chunk buffer to the Stream, topping up the write buffer first. The chunk buffer is sealed if it is full and otherwise kept as the new write buffer. Does not acquire mutex lock.
+func (s *Stream) fill(b *LockedBuffer, n int) {
+	data := b.Bytes()[streamHeaderSize:]
+	if s.pending != nil {
+		i := min(n, s.chunkSize-s.pendingLen)
+		s.pending.MoveAt(streamHeaderSize+s.pendingLen, data[:i])
+		s.pendingLen += i
+		if s.pendingLen == s.chunkSize {
+			s.sealPending() // cannot fail, since the buffer was checked
+		}
+		if i == n {
+			b.Destroy()
+			return
+		}
+		// Shift the rest to the start of the chunk buffer.
+		copy(data, data[i:n])
+		core.Wipe(data[n-i : n])
+		n -= i
+	}
+
+	if n == s.chunkSize {
+		s.join(sealChunkBuffer(b, s.ident.idFor(s.written), s.written))
+		s.written++
+		return
+	}
+	s.pending, s.pendingLen = b, n
+}
+
+// Seals some data into a chunk with the given counter, wiping the source buffer.
+func (s *Stream) seal(counter uint64, data []byte) *Enclave {
+	return sealChunk(s.ident.idFor(counter), counter, data)
+}
+
+// Seals some data into a chunk prefixed with an identifier and counter, wiping the source buffer.
+func sealChunk(id *[streamIDSize]byte, counter uint64, data []byte) *Enclave {
+	b := NewBuffer(streamHeaderSize + len(data))
+	b.MoveAt(streamHeaderSize, data)
+	return sealChunkBuffer(b, id, counter)
+}
+
+// Fills in the header at the start of a buffer holding the data of a chunk and seals it, destroying the buffer.
+func sealChunkBuffer(b *LockedBuffer, id *[streamIDSize]byte, counter uint64) *Enclave {
+	b.Copy(id[:])
+	binary.LittleEndian.PutUint64(b.Bytes()[streamIDSize:streamHeaderSize], counter)
+	return b.Seal()
+}
+
+// Decrypts a chunk and checks that it carries the given identifier and counter. The returned buffer includes the header.
+func openChunk(e *Enclave, id *[streamIDSize]byte, counter uint64) (*LockedBuffer, error) {
+	// Decrypt the data into a guarded allocation.
+	b, err := e.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	if b.Size() <= streamHeaderSize {
+		b.Destroy()
+		return nil, ErrStreamCorrupt
+	}
+	header := b.Bytes()[:streamHeaderSize]
+	if !core.Equal(header[:streamIDSize], id[:]) || binary.LittleEndian.Uint64(header[streamIDSize:]) != counter {
+		b.Destroy()
+		return nil, ErrStreamCorrupt
+	}
+	return b, nil
+}
+
 /*
 Read decrypts and places some data from a Stream object into a provided buffer.
 
 If there is no data, the call will return an io.EOF error. If the caller provides a buffer
 that is too small to hold the next chunk of data, the remaining bytes are re-encrypted and
-added to the front of the queue to be returned in the next call.
+added to the front of the queue to be returned in the next call. The remainder keeps the
+position of the chunk it came from, so the sequence remains intact.
+
+If a chunk cannot be decrypted, or is not the next one in sequence, it is discarded and the error is returned. Subsequent calls carry on with the chunks after it.
 
-To be performant, have
+To be performant, have the buffer be at least as large as the chunk size of the Stream.
 */
 func (s *Stream) Read(buf []byte) (int, error) {
 	s.Lock()
 	defer s.Unlock()
 
 	// Grab the next chunk of data from the stream.
-	b, err := s.next()
+	b, err := s.nextChunk()
 	if err != nil {
 		return 0, err
 	}
 	defer b.Destroy()
+	data := b.Bytes()[streamHeaderSize:]
 
 	// Copy the contents into the given buffer.
-	core.Copy(buf, b.Bytes())
+	core.Copy(buf, data)
 
 	// Check if there is data left over.
-	if len(buf) < b.Size() {
+	if len(buf) < len(data) {
 		// Re-encrypt it and push onto the front of the list.
-		c := NewBuffer(b.Size() - len(buf))
-		c.Copy(b.Bytes()[len(buf):])
-		s.push(c.Seal())
+		c := NewBuffer(len(data) - len(buf))
+		c.Copy(data[len(buf):])
+		s.push(s.seal(s.read, c.Bytes()))
+		c.Destroy()
 		return len(buf), nil
 	}
 
 	// Not enough data or perfect amount of data.
 	// Either way we copied the entire buffer.
-	return b.Size(), nil
+	s.read++
+	return len(data), nil
+}
+
+/*
+WriteTo drains a Stream, writing its contents to w, implementing io.WriterTo. Each chunk is decrypted into a guarded allocation and written to w directly from there, so io.Copy never has to pass the data through an unprotected intermediate buffer. The writer must not retain the slices it is given.
+
+It returns the number of bytes written. If writing fails, the data that was not written remains at the front of the Stream. A chunk that cannot be decrypted is discarded, as it is by Read.
+*/
+func (s *Stream) WriteTo(w io.Writer) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.sealPending(); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for {
+		// Decrypt a batch of chunks in parallel without removing them, so that they are not lost if writing fails.
+		batch := s.frontChunks(s.parallelism)
+		if len(batch) == 0 {
+			return total, s.checkEnd()
+		}
+		bufs, errs := s.openChunks(batch)
+
+		for i, front := range batch {
+			if errs[i] != nil {
+				// Discard the chunk, as Read does.
+				s.Remove(front)
+				s.read++
+				destroyAll(bufs[i:])
+				return total, errs[i]
+			}
+			b := bufs[i]
+			data := b.Bytes()[streamHeaderSize:]
+
+			n, err := w.Write(data)
+			total += int64(n)
+			if err == nil && n < len(data) {
+				err = io.ErrShortWrite
+			}
+			if err != nil {
+				if n > 0 {
+					// Replace the chunk with whatever was left unwritten.
+					c := NewBuffer(len(data) - n)
+					c.Copy(data[n:])
+					front.Value = s.seal(s.read, c.Bytes())
+					c.Destroy()
+				}
+				destroyAll(bufs[i:])
+				return total, err
+			}
+			b.Destroy()
+
+			s.Remove(front)
+			s.read++
+		}
+	}
+}
+
+// Returns up to n elements from the front of the queue. Does not acquire mutex lock.
+func (s *Stream) frontChunks(n int) []*list.Element {
+	var elems []*list.Element
+	for e := s.Front(); e != nil && len(elems) < n; e = e.Next() {
+		elems = append(elems, e)
+	}
+	return elems
+}
+
+// Decrypts the chunks held by a run of elements from the front of the queue in parallel, checking that they are in sequence. Any buffer whose chunk could not be opened is nil, and its error is set. Does not acquire mutex lock.
+func (s *Stream) openChunks(elems []*list.Element) ([]*LockedBuffer, []error) {
+	bufs := make([]*LockedBuffer, len(elems))
+	errs := make([]error, len(elems))
+	parallel(s.parallelism, len(elems), func(i int) {
+		counter := s.read + uint64(i)
+		bufs[i], errs[i] = openChunk(elems[i].Value.(*Enclave), s.ident.idFor(counter), counter)
+	})
+	return bufs, errs
+}
+
+// Destroys every non-nil buffer in a slice.
+func destroyAll(bufs []*LockedBuffer) {
+	for _, b := range bufs {
+		if b != nil {
+			b.Destroy()
+		}
+	}
 }
 
 // Size returns the number of bytes of data currently stored within a Stream object.
@@ -118,9 +540,9 @@ func (s *Stream) Size() int {
 	s.Lock()
 	defer s.Unlock()
 
-	var n int
+	n := s.pendingLen
 	for e := s.Front(); e != nil; e = e.Next() {
-		n += e.Value.(*Enclave).Size()
+		n += e.Value.(*Enclave).Size() - streamHeaderSize
 	}
 	return n
 }
@@ -135,21 +557,282 @@ func (s *Stream) Next() (*LockedBuffer, error) {
 
 // does not acquire mutex lock
 func (s *Stream) next() (*LockedBuffer, error) {
+	b, err := s.nextChunk()
+	if err != nil {
+		return newNullBuffer(), err
+	}
+	defer b.Destroy()
+	s.read++
+
+	// Strip the header.
+	d := NewBuffer(b.Size() - streamHeaderSize)
+	d.Copy(b.Bytes()[streamHeaderSize:])
+	d.Freeze()
+	return d, nil
+}
+
+// Pops the next chunk from the front of the queue and decrypts it, checking that it is the one we expect. The returned buffer includes the header. Does not acquire mutex lock.
+func (s *Stream) nextChunk() (*LockedBuffer, error) {
+	// Anything still waiting to be sealed comes after everything in the list.
+	if s.Front() == nil {
+		if err := s.sealPending(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Pop data from the front of the list.
 	e := s.pop()
 	if e == nil {
-		return newNullBuffer(), io.EOF
+		if err := s.checkEnd(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
 	}
-
-	// Decrypt the data into a guarded allocation.
-	b, err := e.Open()
+	b, err := s.openChunk(e)
 	if err != nil {
-		return newNullBuffer(), err
+		s.read++ // the chunk is discarded, so skip over its counter
+		return nil, err
 	}
 	return b, nil
 }
 
-// Flush reads all of the data from a Stream and returns it inside a LockedBuffer. If an error is encountered before all the data could be read, it is returned along with any data read up until that point.
+// Returns ErrStreamCorrupt if chunks were removed from the end of the queue once it has been drained, catching up with the write counter so that it is only reported once. Does not acquire mutex lock.
+func (s *Stream) checkEnd() error {
+	if s.read != s.written {
+		s.read = s.written
+		return ErrStreamCorrupt
+	}
+	return nil
+}
+
+// Decrypts a chunk and checks that it belongs to this stream and is next in sequence. The returned buffer includes the header. Does not acquire mutex lock.
+func (s *Stream) openChunk(e *Enclave) (*LockedBuffer, error) {
+	return openChunk(e, s.ident.idFor(s.read), s.read)
+}
+
+/*
+Flush reads all of the data from a Stream and returns it inside an immutable LockedBuffer. The chunks are decrypted in parallel, straight into their place in the result.
+
+If an error is encountered before all the data could be read, it is returned along with any data read up until that point. The chunk that could not be read is discarded, as it is by Read, and the chunks after it are left in the Stream.
+*/
 func (s *Stream) Flush() (*LockedBuffer, error) {
-	return NewBufferFromEntireReader(s)
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.sealPending(); err != nil {
+		return newNullBuffer(), err
+	}
+
+	// Work out where each chunk belongs in the result.
+	elems := s.frontChunks(s.Len())
+	offsets := make([]int, len(elems)+1)
+	for i, e := range elems {
+		offsets[i+1] = offsets[i] + e.Value.(*Enclave).Size() - streamHeaderSize
+	}
+
+	b := NewBuffer(offsets[len(elems)])
+	errs := make([]error, len(elems))
+	parallel(s.parallelism, len(elems), func(i int) {
+		counter := s.read + uint64(i)
+		c, err := openChunk(elems[i].Value.(*Enclave), s.ident.idFor(counter), counter)
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		b.CopyAt(offsets[i], c.Bytes()[streamHeaderSize:])
+		c.Destroy()
+	})
+
+	// Consume the chunks up to and including the first one that could not be read.
+	var err error
+	read, consumed := len(elems), len(elems)
+	for i := range errs {
+		if errs[i] != nil {
+			read, consumed, err = i, i+1, errs[i]
+			break
+		}
+	}
+	for _, e := range elems[:consumed] {
+		s.Remove(e)
+	}
+	s.read += uint64(consumed)
+	if err == nil {
+		err = s.checkEnd()
+	}
+
+	switch {
+	case offsets[read] == 0:
+		b.Destroy()
+		return newNullBuffer(), err
+	case read < len(elems):
+		// Only return the data that was read.
+		d := NewBuffer(offsets[read])
+		d.Copy(b.Bytes()[:offsets[read]])
+		b.Destroy()
+		b = d
+	}
+	b.Freeze()
+	return b, err
+}
+
+/*
+Peek returns the next n bytes of a Stream inside an immutable LockedBuffer, without consuming them. If the Stream holds fewer than n bytes, all of them are returned along with io.EOF.
+*/
+func (s *Stream) Peek(n int) (*LockedBuffer, error) {
+	if n < 1 {
+		return newNullBuffer(), nil
+	}
+	r := s.NewReader()
+
+	// Read directly into the guarded allocation.
+	b := NewBuffer(min(n, r.size))
+	if _, err := io.ReadFull(r, b.Bytes()); err != nil {
+		b.Destroy()
+		return newNullBuffer(), err
+	}
+	b.Freeze()
+
+	if b.Size() < n {
+		// We should be at the end of the stream, so this reports io.EOF unless chunks are missing.
+		_, err := r.Read(nil)
+		return b, err
+	}
+	return b, nil
+}
+
+/*
+Clone returns a new Stream holding the same data as s, which can be read independently of it. The chunks are shared rather than copied: since the Enclave holding a chunk is never modified, reading or writing either stream replaces chunks instead of altering them, and the other stream is unaffected.
+
+Data written to the clone is sealed with a new identifier, so chunks written to one of the streams after it was cloned cannot be substituted into the other. A clone of a closed Stream is also closed.
+*/
+func (s *Stream) Clone() *Stream {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.pendingLost() {
+		s.sealPending() // cannot fail, and if data was lost the original will report it
+	}
+
+	c := &Stream{
+		queue:       &queue{List: list.New()},
+		chunkSize:   s.chunkSize,
+		parallelism: s.parallelism,
+		ident:       s.ident.fork(s.read, s.written),
+		written:     s.written,
+		read:        s.read,
+		closed:      s.closed,
+	}
+	for e := s.Front(); e != nil; e = e.Next() {
+		c.join(e.Value.(*Enclave))
+	}
+	return c
+}
+
+/*
+Compact merges undersized chunks in a Stream so that every chunk but the last is full. Undersized chunks are left behind when data is needed before a full chunk has been written, or when Read is called with a buffer smaller than a chunk. Merging them saves memory and makes subsequent reads cheaper.
+
+If there are undersized chunks, all of the data is decrypted and resealed with a new identifier, one chunk at a time. If an error is encountered, the Stream is left unchanged.
+*/
+func (s *Stream) Compact() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.sealPending(); err != nil {
+		return err
+	}
+
+	// There is nothing to do if every chunk but the last is full.
+	full := true
+	for e := s.Front(); e != nil && e.Next() != nil; e = e.Next() {
+		if e.Value.(*Enclave).Size()-streamHeaderSize < s.chunkSize {
+			full = false
+			break
+		}
+	}
+	if full {
+		return nil
+	}
+
+	ident := newStreamIdentity()
+	chunks := &queue{List: list.New()}
+	var written uint64
+
+	var b *LockedBuffer // chunk being filled, with space for the header
+	var n int           // number of bytes of data in b
+	read := s.read
+	for e := s.Front(); e != nil; e = e.Next() {
+		c, err := openChunk(e.Value.(*Enclave), s.ident.idFor(read), read)
+		if err != nil {
+			if b != nil {
+				b.Destroy()
+			}
+			return err
+		}
+		read++
+
+		for data := c.Bytes()[streamHeaderSize:]; len(data) > 0; {
+			if b == nil {
+				b = NewBuffer(streamHeaderSize + s.chunkSize)
+			}
+			m := min(len(data), s.chunkSize-n)
+			b.CopyAt(streamHeaderSize+n, data[:m])
+			n += m
+			data = data[m:]
+
+			if n == s.chunkSize {
+				chunks.join(sealChunkBuffer(b, &ident.id, written))
+				written++
+				b, n = nil, 0
+			}
+		}
+		c.Destroy()
+	}
+	if read != s.written {
+		if b != nil {
+			b.Destroy()
+		}
+		return ErrStreamCorrupt // chunks were removed from the end
+	}
+	if b != nil {
+		chunks.join(sealChunk(&ident.id, written, b.Bytes()[streamHeaderSize:streamHeaderSize+n]))
+		written++
+		b.Destroy()
+	}
+
+	s.queue = chunks
+	s.ident = ident
+	s.read, s.written = 0, written
+	return nil
+}
+
+/*
+Close discards all of the data held by a Stream, implementing io.Closer. Subsequent writes fail with ErrStreamClosed, while reads behave as they would on an empty Stream. Closing a Stream more than once has no effect, and the error returned is always nil.
+
+The chunks are only dereferenced, since they may be shared with clones of the Stream, and are collected by the garbage collector. They remain encrypted until then.
+*/
+func (s *Stream) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.discard()
+	s.closed = true
+	return nil
+}
+
+// Reset discards all of the data held by a Stream, returning it to the state of a newly created Stream with the same chunk size. A closed Stream is reopened.
+func (s *Stream) Reset() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.discard()
+	s.ident = newStreamIdentity()
+	s.written, s.read = 0, 0
+	s.closed = false
+}
+
+// Drops all of the chunks and destroys the write buffer. Does not acquire mutex lock.
+func (s *Stream) discard() {
+	s.discardPending()
+	s.Init()
+	s.read = s.written
 }
@@ -0,0 +1,215 @@
+package memguard
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/awnumar/memguard/core"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// ErrInvalidStreamFile is returned when an encrypted stream is truncated, has been modified or was encrypted under a different key.
+var ErrInvalidStreamFile = errors.New("<memguard::ErrInvalidStreamFile> encrypted stream is corrupt, truncated or under a different key")
+
+// ErrUnsupportedStreamFile is returned when reading data that is not an encrypted stream, or is of a version that is not supported.
+var ErrUnsupportedStreamFile = errors.New("<memguard::ErrUnsupportedStreamFile> not an encrypted stream of a supported version")
+
+/*
+The encrypted stream format consists of a header followed by a sequence of records.
+
+The header is made up of a four byte magic value, a version byte, the chunk size of the stream as a big-endian uint32 and a random 15 byte nonce prefix. It is authenticated as additional data with every record.
+
+Each record consists of a flag byte, which is 1 for the final record and 0 otherwise, the length of its ciphertext as a big-endian uint32 and the ciphertext itself. Chunks are encrypted with XChaCha20-Poly1305 using a nonce made up of the prefix, the index of the record as a big-endian uint64 and the flag byte, as in the STREAM construction. An empty stream is encoded as a single empty final record.
+*/
+const (
+	streamFileMagic      = "MGSF"
+	streamFileVersion    = 1
+	streamFilePrefixSize = 15
+	streamFileHeaderSize = len(streamFileMagic) + 1 + 4 + streamFilePrefixSize
+	streamRecordHeader   = 1 + 4
+	maxStreamFileChunk   = 1 << 24 // bounds the allocation made for a record before it is authenticated
+)
+
+/*
+WriteEncryptedTo drains a Stream, writing its contents to w encrypted under a 32 byte key held inside an Enclave. Each chunk is decrypted into a LockedBuffer and encrypted directly from there, so no plaintext is ever written to w or held in unprotected memory. The data can be loaded back with ReadEncryptedStream.
+
+It returns the number of bytes written. If writing fails, the chunk that was being written remains at the front of the Stream. A chunk that cannot be decrypted is discarded, as it is by Read. Streams with chunks larger than 16 MiB cannot be written, and ErrUnsupportedStreamFile is returned for them.
+*/
+func (s *Stream) WriteEncryptedTo(w io.Writer, key *Enclave) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.chunkSize > maxStreamFileChunk {
+		return 0, ErrUnsupportedStreamFile
+	}
+	c, err := streamFileCipher(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.sealPending(); err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, streamFileHeaderSize)
+	copy(header, streamFileMagic)
+	header[len(streamFileMagic)] = streamFileVersion
+	binary.BigEndian.PutUint32(header[len(streamFileMagic)+1:], uint32(s.chunkSize))
+	if err := core.Scramble(header[len(streamFileMagic)+5:]); err != nil {
+		core.Panic(err)
+	}
+
+	var total int64
+	n, err := w.Write(header)
+	total += int64(n)
+	if err != nil {
+		return total, err
+	}
+
+	for counter := uint64(0); ; counter++ {
+		// Decrypt the chunk without removing it, so that it is not lost if writing fails.
+		var b *LockedBuffer
+		front := s.Front()
+		if front != nil {
+			if b, err = s.openChunk(front.Value.(*Enclave)); err != nil {
+				// Discard the chunk, as Read does.
+				s.Remove(front)
+				s.read++
+				return total, err
+			}
+		} else if err := s.checkEnd(); err != nil {
+			return total, err
+		}
+		last := front == nil || front.Next() == nil
+
+		var data []byte
+		if b != nil {
+			data = b.Bytes()[streamHeaderSize:]
+		}
+		record := make([]byte, streamRecordHeader, streamRecordHeader+len(data)+c.Overhead())
+		if last {
+			record[0] = 1
+		}
+		binary.BigEndian.PutUint32(record[1:], uint32(len(data)+c.Overhead()))
+		record = c.Seal(record, streamFileNonce(header, counter, record[0]), data, header)
+		if b != nil {
+			b.Destroy()
+		}
+
+		n, err := w.Write(record)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+
+		if front != nil {
+			s.Remove(front)
+			s.read++
+		}
+		if last {
+			return total, nil
+		}
+	}
+}
+
+/*
+ReadEncryptedStream reads a Stream that was written by WriteEncryptedTo, decrypting it with the same key. Each chunk is decrypted directly into a LockedBuffer and sealed into the new Stream, which has the chunk size of the original. Exactly the encoded data is consumed from r.
+
+If the data has been truncated or modified, or the key is wrong, ErrInvalidStreamFile is returned.
+*/
+func ReadEncryptedStream(r io.Reader, key *Enclave) (*Stream, error) {
+	c, err := streamFileCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, streamFileHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, streamFileError(err)
+	}
+	if string(header[:len(streamFileMagic)]) != streamFileMagic || header[len(streamFileMagic)] != streamFileVersion {
+		return nil, ErrUnsupportedStreamFile
+	}
+	chunkSize := binary.BigEndian.Uint32(header[len(streamFileMagic)+1:])
+	if chunkSize < 1 || chunkSize > maxStreamFileChunk {
+		return nil, ErrInvalidStreamFile
+	}
+
+	s := NewStreamWithOptions(StreamOptions{ChunkSize: int(chunkSize)})
+	recordHeader := make([]byte, streamRecordHeader)
+	for counter := uint64(0); counter < math.MaxUint64; counter++ {
+		if _, err := io.ReadFull(r, recordHeader); err != nil {
+			return nil, streamFileError(err)
+		}
+		flag := recordHeader[0]
+		length := binary.BigEndian.Uint32(recordHeader[1:])
+		if flag > 1 || length < uint32(c.Overhead()) || length > chunkSize+uint32(c.Overhead()) {
+			return nil, ErrInvalidStreamFile
+		}
+		ciphertext := make([]byte, length)
+		if _, err := io.ReadFull(r, ciphertext); err != nil {
+			return nil, streamFileError(err)
+		}
+		nonce := streamFileNonce(header, counter, flag)
+
+		size := int(length) - c.Overhead()
+		if size == 0 {
+			// Only the final record may be empty.
+			if flag != 1 {
+				return nil, ErrInvalidStreamFile
+			}
+			if _, err := c.Open(nil, nonce, ciphertext, header); err != nil {
+				return nil, ErrInvalidStreamFile
+			}
+			return s, nil
+		}
+
+		// Decrypt directly into the guarded allocation.
+		b := NewBuffer(size)
+		if _, err := c.Open(b.Bytes()[:0], nonce, ciphertext, header); err != nil {
+			b.Destroy()
+			return nil, ErrInvalidStreamFile
+		}
+		s.join(s.seal(s.written, b.Bytes()))
+		s.written++
+		b.Destroy()
+
+		if flag == 1 {
+			return s, nil
+		}
+	}
+	return nil, ErrInvalidStreamFile
+}
+
+// Decrypts the key and constructs the cipher used by the encrypted stream format.
+func streamFileCipher(key *Enclave) (cipher.AEAD, error) {
+	if key.Size() != chacha20poly1305.KeySize {
+		return nil, core.ErrInvalidKeyLength
+	}
+	k, err := key.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer k.Destroy()
+
+	return newAEADCipher(k.Bytes(), XChaCha20Poly1305)
+}
+
+// Constructs the nonce for a record from the nonce prefix in the header, the record index and the final record flag.
+func streamFileNonce(header []byte, counter uint64, flag byte) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	copy(nonce, header[streamFileHeaderSize-streamFilePrefixSize:])
+	binary.BigEndian.PutUint64(nonce[streamFilePrefixSize:], counter)
+	nonce[len(nonce)-1] = flag
+	return nonce
+}
+
+// Converts an early end of input into ErrInvalidStreamFile.
+func streamFileError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrInvalidStreamFile
+	}
+	return err
+}
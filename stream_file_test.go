@@ -0,0 +1,172 @@
+package memguard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/awnumar/memguard/core"
+)
+
+func TestStreamEncryptedFile(t *testing.T) {
+	key := NewEnclaveRandom(32)
+
+	for _, size := range []int{0, 1, 100, 1000, 4096} {
+		s := NewStreamWithOptions(StreamOptions{ChunkSize: 100})
+		data := make([]byte, size)
+		ScrambleBytes(data)
+		ref := make([]byte, size)
+		copy(ref, data)
+		if size > 0 {
+			write(t, s, data)
+		}
+
+		var file bytes.Buffer
+		n, err := s.WriteEncryptedTo(&file, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(file.Len()) {
+			t.Error("reported", n, "bytes written; wrote", file.Len())
+		}
+		if s.Size() != 0 {
+			t.Error("stream was not drained")
+		}
+		if size > 16 && bytes.Contains(file.Bytes(), ref[:16]) {
+			t.Error("plaintext was written")
+		}
+
+		// Trailing data should be left alone.
+		file.WriteString("trailer")
+		r, err := ReadEncryptedStream(&file, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file.String() != "trailer" {
+			t.Error("unexpected amount of data consumed")
+		}
+		if r.ChunkSize() != 100 {
+			t.Error("chunk size not preserved")
+		}
+		if r.Size() != size {
+			t.Error("unexpected size", r.Size(), "want", size)
+		}
+		if size > 0 {
+			b, err := r.Flush()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !b.EqualTo(ref) {
+				t.Error("data does not match")
+			}
+			b.Destroy()
+		}
+	}
+}
+
+func TestStreamEncryptedFileTampering(t *testing.T) {
+	key := NewEnclaveRandom(32)
+	s := NewStreamWithOptions(StreamOptions{ChunkSize: 100})
+	write(t, s, make([]byte, 250))
+	var file bytes.Buffer
+	if _, err := s.WriteEncryptedTo(&file, key); err != nil {
+		t.Fatal(err)
+	}
+	encoded := file.Bytes()
+	record := 5 + 100 + 16
+
+	// Wrong key.
+	if _, err := ReadEncryptedStream(bytes.NewReader(encoded), NewEnclaveRandom(32)); err != ErrInvalidStreamFile {
+		t.Error("expected ErrInvalidStreamFile; got", err)
+	}
+
+	// Every bit flip should be detected.
+	for i := range encoded {
+		tampered := append([]byte{}, encoded...)
+		tampered[i] ^= 0x10
+		if _, err := ReadEncryptedStream(bytes.NewReader(tampered), key); err == nil {
+			t.Fatal("tampering at offset", i, "was not detected")
+		}
+	}
+
+	// Truncation, including at a record boundary.
+	for _, n := range []int{0, 10, streamFileHeaderSize, streamFileHeaderSize + record, len(encoded) - 1} {
+		if _, err := ReadEncryptedStream(bytes.NewReader(encoded[:n]), key); err != ErrInvalidStreamFile {
+			t.Error("truncation to", n, "bytes: expected ErrInvalidStreamFile; got", err)
+		}
+	}
+
+	// Reordered records.
+	h := streamFileHeaderSize
+	swapped := append([]byte{}, encoded[:h]...)
+	swapped = append(swapped, encoded[h+record:h+2*record]...)
+	swapped = append(swapped, encoded[h:h+record]...)
+	swapped = append(swapped, encoded[h+2*record:]...)
+	if _, err := ReadEncryptedStream(bytes.NewReader(swapped), key); err != ErrInvalidStreamFile {
+		t.Error("expected ErrInvalidStreamFile; got", err)
+	}
+
+	// Not an encrypted stream, or an unknown version.
+	if _, err := ReadEncryptedStream(bytes.NewReader(make([]byte, 64)), key); err != ErrUnsupportedStreamFile {
+		t.Error("expected ErrUnsupportedStreamFile; got", err)
+	}
+	future := append([]byte{}, encoded...)
+	future[len(streamFileMagic)] = streamFileVersion + 1
+	if _, err := ReadEncryptedStream(bytes.NewReader(future), key); err != ErrUnsupportedStreamFile {
+		t.Error("expected ErrUnsupportedStreamFile; got", err)
+	}
+
+	// Oversized chunks are refused before anything is allocated for them.
+	huge := append([]byte{}, encoded...)
+	binary.BigEndian.PutUint32(huge[len(streamFileMagic)+1:], maxStreamFileChunk+1)
+	if _, err := ReadEncryptedStream(bytes.NewReader(huge), key); err != ErrInvalidStreamFile {
+		t.Error("expected ErrInvalidStreamFile; got", err)
+	}
+	large := NewStreamWithOptions(StreamOptions{ChunkSize: maxStreamFileChunk + 1})
+	if _, err := large.WriteEncryptedTo(io.Discard, key); err != ErrUnsupportedStreamFile {
+		t.Error("expected ErrUnsupportedStreamFile; got", err)
+	}
+
+	// Keys must be 32 bytes.
+	if _, err := s.WriteEncryptedTo(io.Discard, NewEnclaveRandom(16)); err != core.ErrInvalidKeyLength {
+		t.Error("expected ErrInvalidKeyLength; got", err)
+	}
+}
+
+// failingWriter fails after accepting a given number of writes.
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.writes == 0 {
+		return 0, io.ErrShortWrite
+	}
+	w.writes--
+	return len(p), nil
+}
+
+func TestStreamEncryptedFileWriteError(t *testing.T) {
+	key := NewEnclaveRandom(32)
+	s := NewStreamWithOptions(StreamOptions{ChunkSize: 100})
+	data := make([]byte, 250)
+	ScrambleBytes(data)
+	ref := append([]byte{}, data...)
+	write(t, s, data)
+
+	// Header and first record succeed, then writing fails.
+	if _, err := s.WriteEncryptedTo(&failingWriter{writes: 2}, key); err != io.ErrShortWrite {
+		t.Fatal("expected io.ErrShortWrite; got", err)
+	}
+
+	// The unwritten data should still be in the stream.
+	b, err := s.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.EqualTo(ref[100:]) {
+		t.Error("unwritten data was lost")
+	}
+	b.Destroy()
+}
@@ -0,0 +1,118 @@
+package memguard
+
+import (
+	"io"
+
+	"github.com/awnumar/memguard/core"
+)
+
+/*
+StreamReader reads the data held by a Stream without consuming it. It is created by Stream.NewReader.
+
+A StreamReader is a cursor over the chunks that the Stream held when it was created, and is unaffected by later reads and writes. Each call decrypts a single chunk into locked memory and wipes it before returning, so no plaintext is kept between calls. A StreamReader is not safe for concurrent use.
+*/
+type StreamReader struct {
+	chunks []*Enclave
+	ident  streamIdentity
+	first  uint64 // counter of the first chunk
+	end    uint64 // counter of the chunk after the last
+	size   int
+	err    error // returned instead of io.EOF, if data was lost before the reader was created
+
+	index  int // index of the current chunk
+	offset int // number of bytes already read from the current chunk
+}
+
+// NewReader returns a StreamReader that reads the current contents of a Stream from the beginning, leaving the Stream itself untouched.
+func (s *Stream) NewReader() *StreamReader {
+	s.Lock()
+	defer s.Unlock()
+
+	// Leave lost data for the Stream to report as well.
+	var err error
+	if s.pendingLost() {
+		err = core.ErrBufferExpired
+	} else {
+		s.sealPending() // cannot fail
+	}
+
+	r := &StreamReader{
+		chunks: make([]*Enclave, 0, s.Len()),
+		ident:  s.ident,
+		first:  s.read,
+		end:    s.written,
+		err:    err,
+	}
+	for e := s.Front(); e != nil; e = e.Next() {
+		c := e.Value.(*Enclave)
+		r.chunks = append(r.chunks, c)
+		r.size += c.Size() - streamHeaderSize
+	}
+	return r
+}
+
+/*
+Read decrypts and places data from the current chunk into a provided buffer, advancing the cursor. It reads from at most one chunk per call.
+
+If there is no more data, io.EOF is returned. If the chunks of the Stream were tampered with, ErrStreamCorrupt is returned.
+*/
+func (r *StreamReader) Read(buf []byte) (int, error) {
+	b, err := r.open()
+	if err != nil {
+		return 0, err
+	}
+	defer b.Destroy()
+	data := b.Bytes()[streamHeaderSize+r.offset:]
+
+	n := copy(buf, data)
+	r.advance(n, len(data))
+	return n, nil
+}
+
+// Next returns the unread remainder of the current chunk decrypted inside an immutable LockedBuffer, advancing the cursor to the following chunk. Any error is forwarded as in Read.
+func (r *StreamReader) Next() (*LockedBuffer, error) {
+	b, err := r.open()
+	if err != nil {
+		return newNullBuffer(), err
+	}
+	defer b.Destroy()
+	data := b.Bytes()[streamHeaderSize+r.offset:]
+
+	d := NewBuffer(len(data))
+	d.Copy(data)
+	d.Freeze()
+	r.advance(len(data), len(data))
+	return d, nil
+}
+
+// Len returns the number of bytes of data that have not yet been read.
+func (r *StreamReader) Len() int {
+	return r.size
+}
+
+// Decrypts the current chunk, checking that it is the one we expect. The returned buffer includes the header.
+func (r *StreamReader) open() (*LockedBuffer, error) {
+	if r.index == len(r.chunks) {
+		if r.first+uint64(r.index) != r.end {
+			return nil, ErrStreamCorrupt // chunks were removed from the end
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		return nil, io.EOF
+	}
+	counter := r.first + uint64(r.index)
+	return openChunk(r.chunks[r.index], r.ident.idFor(counter), counter)
+}
+
+// Records that n of the remaining bytes of the current chunk have been read, moving on to the next chunk once they are exhausted.
+func (r *StreamReader) advance(n, remaining int) {
+	r.size -= n
+	if n < remaining {
+		r.offset += n
+		return
+	}
+	r.chunks[r.index] = nil
+	r.index++
+	r.offset = 0
+}
@@ -2,10 +2,13 @@ package memguard
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"os"
 	"runtime"
+	"strconv"
 	"testing"
+	"testing/iotest"
 
 	"github.com/awnumar/memguard/core"
 )
@@ -123,11 +126,64 @@ func TestStreamReadWrite(t *testing.T) {
 	read(t, s, make([]byte, 16), nil)
 	read(t, s, nil, io.EOF)
 
-	// Test reading after purging the session
+	// Test reading after purging the session. Data waiting in the write buffer is destroyed, which is reported once.
 	ScrambleBytes(data)
 	write(t, s, data)
 	Purge()
+	read(t, s, nil, core.ErrBufferExpired)
+	read(t, s, nil, io.EOF)
+	for i := 0; i < 3; i++ {
+		write(t, s, []byte("x"))
+		read(t, s, []byte("x"), nil)
+	}
+
+	// Writes notice the loss too, leaving the data to be written again.
+	write(t, s, []byte("yellow"))
+	Purge()
+	data = []byte("submarine")
+	if n, err := s.Write(data); n != 0 || err != core.ErrBufferExpired {
+		t.Error("expected ErrBufferExpired; got", n, err)
+	}
+	if string(data) != "submarine" {
+		t.Error("data should not have been wiped")
+	}
+	write(t, s, data)
+	read(t, s, []byte("submarine"), nil)
+	read(t, s, nil, io.EOF)
+
+	// Sealed chunks can no longer be decrypted. They are discarded, and the stream carries on.
+	s.Reset()
+	write(t, s, make([]byte, StreamChunkSize))
+	Purge()
 	read(t, s, nil, core.ErrDecryptionFailed)
+	read(t, s, nil, io.EOF)
+	write(t, s, []byte("x"))
+	read(t, s, []byte("x"), nil)
+	read(t, s, nil, io.EOF)
+
+	// The same goes for Next and Flush.
+	write(t, s, make([]byte, StreamChunkSize))
+	Purge()
+	if _, err := s.Next(); err != core.ErrDecryptionFailed {
+		t.Error("expected ErrDecryptionFailed; got", err)
+	}
+	read(t, s, nil, io.EOF)
+	write(t, s, make([]byte, 2*StreamChunkSize))
+	Purge()
+	if _, err := s.Flush(); err != core.ErrDecryptionFailed {
+		t.Error("expected ErrDecryptionFailed; got", err)
+	}
+	if s.Len() != 1 {
+		t.Error("expected one chunk left; got", s.Len())
+	}
+	if _, err := s.Flush(); err != core.ErrDecryptionFailed {
+		t.Error("expected ErrDecryptionFailed; got", err)
+	}
+	write(t, s, []byte("x"))
+	if b, err := s.Flush(); err != nil || !b.EqualTo([]byte("x")) {
+		t.Error("unexpected result from flush", err)
+	}
+	read(t, s, nil, io.EOF)
 }
 
 func TestStreamingSanity(t *testing.T) {
@@ -216,6 +272,576 @@ func TestStreamSize(t *testing.T) {
 	}
 }
 
+func TestStreamChunkSize(t *testing.T) {
+	for _, size := range []int{1, 3, 17, os.Getpagesize(), 1 << 20} {
+		s := NewStreamWithOptions(StreamOptions{ChunkSize: size})
+		if s.ChunkSize() != size {
+			t.Error("unexpected chunk size", s.ChunkSize(), "want", size)
+		}
+
+		// Write enough data for two full chunks and one partial chunk.
+		data := make([]byte, 2*size+size/2+1)
+		ScrambleBytes(data)
+		ref := make([]byte, len(data))
+		copy(ref, data)
+		write(t, s, data)
+
+		// The partial chunk is held back until it is needed.
+		if s.Len() != len(data)/size {
+			t.Error("expected", len(data)/size, "sealed chunks; got", s.Len())
+		}
+		for i := 0; i < 2; i++ {
+			c, err := s.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !c.EqualTo(ref[i*size : (i+1)*size]) {
+				t.Error("incorrect data in chunk", i)
+			}
+			c.Destroy()
+		}
+		c, err := s.Flush()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !c.EqualTo(ref[2*size:]) {
+			t.Error("incorrect data in final chunk")
+		}
+		c.Destroy()
+	}
+
+	// The global default should be honoured by streams created after it is changed.
+	defer func(size int) { StreamChunkSize = size }(StreamChunkSize)
+	StreamChunkSize = 100
+	s := NewStream()
+	if s.ChunkSize() != 100 {
+		t.Error("StreamChunkSize not honoured; got", s.ChunkSize())
+	}
+	write(t, s, make([]byte, 250))
+	if s.Len() != 2 || s.Size() != 250 {
+		t.Error("expected 2 sealed chunks and 250 bytes; got", s.Len(), s.Size())
+	}
+
+	// Invalid values fall back to the default.
+	StreamChunkSize = 0
+	if NewStream().ChunkSize() != defaultChunkSize {
+		t.Error("expected default chunk size")
+	}
+}
+
+func TestStreamTampering(t *testing.T) {
+	// Returns a stream holding three chunks.
+	setup := func() *Stream {
+		s := NewStreamWithOptions(StreamOptions{ChunkSize: 16})
+		write(t, s, make([]byte, 48))
+		return s
+	}
+	expectCorrupt := func(s *Stream) {
+		t.Helper()
+		for {
+			if _, err := s.Read(make([]byte, 16)); err != nil {
+				if err != ErrStreamCorrupt {
+					t.Error("expected ErrStreamCorrupt; got", err)
+				}
+				return
+			}
+		}
+	}
+
+	// Reordered chunks.
+	s := setup()
+	s.MoveToBack(s.Front())
+	expectCorrupt(s)
+
+	// Duplicated chunk.
+	s = setup()
+	s.PushFront(s.Front().Value)
+	expectCorrupt(s)
+
+	// Chunk removed from the middle.
+	s = setup()
+	s.Remove(s.Front().Next())
+	expectCorrupt(s)
+
+	// Chunk removed from the end.
+	s = setup()
+	s.Remove(s.Back())
+	expectCorrupt(s)
+
+	// Chunk spliced in from another stream.
+	s = setup()
+	other := setup()
+	s.InsertAfter(other.Front().Value, s.Front())
+	s.Remove(s.Front().Next().Next())
+	expectCorrupt(s)
+
+	// Partial reads should keep the sequence intact.
+	s = setup()
+	for i := 0; i < 3; i++ {
+		read(t, s, make([]byte, 5), nil)
+		read(t, s, make([]byte, 11), nil)
+	}
+	read(t, s, nil, io.EOF)
+}
+
+func TestStreamPeek(t *testing.T) {
+	s := NewStreamWithOptions(StreamOptions{ChunkSize: 16})
+	ref := make([]byte, 40)
+	ScrambleBytes(ref)
+	write(t, s, append([]byte{}, ref...))
+
+	// Spanning chunks.
+	b, err := s.Peek(20)
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !b.EqualTo(ref[:20]) || b.IsMutable() {
+		t.Error("unexpected peeked data")
+	}
+	b.Destroy()
+
+	// Peeking should not consume anything.
+	if s.Size() != len(ref) {
+		t.Error("peek consumed data; size", s.Size())
+	}
+
+	// Partial reads are taken into account.
+	read(t, s, ref[:5], nil)
+	b, err = s.Peek(100)
+	if err != io.EOF {
+		t.Error("expected io.EOF; got", err)
+	}
+	if !b.EqualTo(ref[5:]) {
+		t.Error("unexpected peeked data")
+	}
+	b.Destroy()
+
+	if b, err := s.Peek(0); err != nil || b.Size() != 0 {
+		t.Error("expected nothing from zero-sized peek")
+	}
+
+	read(t, s, ref[5:16], nil)
+	read(t, s, ref[16:32], nil)
+	read(t, s, ref[32:], nil)
+	if b, err := s.Peek(1); err != io.EOF || b.IsAlive() {
+		t.Error("expected io.EOF from empty stream; got", err)
+	}
+
+	// Tampering is detected.
+	s = NewStreamWithOptions(StreamOptions{ChunkSize: 16})
+	write(t, s, make([]byte, 48))
+	s.Remove(s.Back())
+	if _, err := s.Peek(48); err != ErrStreamCorrupt {
+		t.Error("expected ErrStreamCorrupt; got", err)
+	}
+}
+
+func TestStreamClone(t *testing.T) {
+	s := NewStreamWithOptions(StreamOptions{ChunkSize: 16})
+	ref := make([]byte, 40)
+	ScrambleBytes(ref)
+	write(t, s, append([]byte{}, ref...))
+	read(t, s, ref[:5], nil)
+
+	c := s.Clone()
+	if c.ChunkSize() != s.ChunkSize() || c.Size() != s.Size() {
+		t.Error("clone does not match original")
+	}
+
+	// Diverge the two streams.
+	extra := []byte("yellow submarine")
+	write(t, c, append([]byte{}, extra...))
+	read(t, s, ref[5:16], nil)
+
+	b, err := c.Flush()
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !b.EqualTo(append(append([]byte{}, ref[5:]...), extra...)) {
+		t.Error("unexpected data in clone")
+	}
+	b.Destroy()
+
+	b, err = s.Flush()
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !b.EqualTo(ref[16:]) {
+		t.Error("unexpected data in original")
+	}
+	b.Destroy()
+
+	// Clones of clones.
+	s = NewStreamWithOptions(StreamOptions{ChunkSize: 16})
+	write(t, s, make([]byte, 16))
+	c = s.Clone()
+	write(t, c, make([]byte, 16))
+	cc := c.Clone()
+	write(t, cc, make([]byte, 16))
+	if b, err := cc.Flush(); err != nil || b.Size() != 48 {
+		t.Error("unexpected result from clone of clone:", b.Size(), err)
+	}
+
+	// Chunks written after cloning cannot be swapped between the streams.
+	s = NewStreamWithOptions(StreamOptions{ChunkSize: 16})
+	write(t, s, make([]byte, 16))
+	c = s.Clone()
+	write(t, s, make([]byte, 16))
+	write(t, c, make([]byte, 16))
+	s.Back().Value = c.Back().Value
+	if _, err := s.Flush(); err != ErrStreamCorrupt {
+		t.Error("expected ErrStreamCorrupt; got", err)
+	}
+}
+
+func TestStreamReader(t *testing.T) {
+	s := NewStreamWithOptions(StreamOptions{ChunkSize: 16})
+	ref := make([]byte, 40)
+	ScrambleBytes(ref)
+	write(t, s, append([]byte{}, ref...))
+
+	// Read the stream twice.
+	for i := 0; i < 2; i++ {
+		r := s.NewReader()
+		if r.Len() != len(ref) {
+			t.Error("unexpected length", r.Len())
+		}
+		buf := make([]byte, 5)
+		if n, err := r.Read(buf); err != nil || n != 5 || !bytes.Equal(buf, ref[:5]) {
+			t.Error("unexpected read", n, err)
+		}
+		b, err := r.Next()
+		if err != nil || !b.EqualTo(ref[5:16]) {
+			t.Error("unexpected chunk", err)
+		}
+		b.Destroy()
+		rest, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(rest, ref[16:]) {
+			t.Error("unexpected remainder", err)
+		}
+		if r.Len() != 0 {
+			t.Error("expected nothing left")
+		}
+		if _, err := r.Read(buf); err != io.EOF {
+			t.Error("expected io.EOF; got", err)
+		}
+	}
+
+	// The reader is unaffected by later changes to the stream.
+	r := s.NewReader()
+	read(t, s, ref[:16], nil)
+	write(t, s, make([]byte, 16))
+	rest, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(rest, ref) {
+		t.Error("unexpected data from reader", err)
+	}
+
+	// Tampering is detected.
+	s = NewStreamWithOptions(StreamOptions{ChunkSize: 16})
+	write(t, s, make([]byte, 48))
+	s.MoveToBack(s.Front())
+	if _, err := io.ReadAll(s.NewReader()); err != ErrStreamCorrupt {
+		t.Error("expected ErrStreamCorrupt; got", err)
+	}
+}
+
+func TestStreamReadFromWriteTo(t *testing.T) {
+	s := NewStreamWithOptions(StreamOptions{ChunkSize: 16})
+	ref := make([]byte, 40)
+	ScrambleBytes(ref)
+
+	// Short reads should still fill entire chunks.
+	n, err := io.Copy(s, iotest.OneByteReader(bytes.NewReader(ref)))
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if n != int64(len(ref)) || s.Size() != len(ref) {
+		t.Error("unexpected size", n, s.Size())
+	}
+	if s.Len() != 2 || s.pendingLen != 8 {
+		t.Error("expected 2 sealed chunks and 8 pending bytes; got", s.Len(), s.pendingLen)
+	}
+
+	var out bytes.Buffer
+	n, err = io.Copy(&out, s)
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if n != int64(len(ref)) || !bytes.Equal(out.Bytes(), ref) {
+		t.Error("unexpected data")
+	}
+	if s.Size() != 0 {
+		t.Error("stream should be drained")
+	}
+
+	// Earlier writes should be topped up before new chunks are started.
+	write(t, s, append([]byte{}, ref[:5]...))
+	if _, err := s.ReadFrom(bytes.NewReader(ref[5:])); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if s.Len() != 2 || s.pendingLen != 8 {
+		t.Error("expected 2 sealed chunks and 8 pending bytes; got", s.Len(), s.pendingLen)
+	}
+	out.Reset()
+	if _, err := io.Copy(&out, s); err != nil || !bytes.Equal(out.Bytes(), ref) {
+		t.Error("unexpected data", err)
+	}
+
+	// Errors from the reader are returned, keeping what was read.
+	s = NewStreamWithOptions(StreamOptions{ChunkSize: 16})
+	errTest := errors.New("test error")
+	n, err = s.ReadFrom(io.MultiReader(bytes.NewReader(ref[:20]), iotest.ErrReader(errTest)))
+	if err != errTest || n != 20 {
+		t.Error("expected test error after 20 bytes; got", n, err)
+	}
+	read(t, s, ref[:16], nil)
+	read(t, s, ref[16:20], nil)
+	read(t, s, nil, io.EOF)
+
+	// Unwritten data remains in the stream.
+	write(t, s, append([]byte{}, ref...))
+	n, err = s.WriteTo(&shortWriter{limit: 20})
+	if err != io.ErrShortWrite || n != 20 {
+		t.Error("expected short write after 20 bytes; got", n, err)
+	}
+	b, err := s.Flush()
+	if err != nil || !b.EqualTo(ref[20:]) {
+		t.Error("unexpected remaining data", err)
+	}
+	b.Destroy()
+
+	// Tampering is detected.
+	write(t, s, make([]byte, 48))
+	s.Remove(s.Back())
+	if _, err := s.WriteTo(io.Discard); err != ErrStreamCorrupt {
+		t.Error("expected ErrStreamCorrupt; got", err)
+	}
+	read(t, s, nil, io.EOF) // only reported once
+
+	// Data lost to a purge is reported once the new data has been added.
+	write(t, s, []byte("yellow"))
+	Purge()
+	if _, err := io.ReadAll(s.NewReader()); err != core.ErrBufferExpired {
+		t.Error("expected ErrBufferExpired from reader; got", err)
+	}
+	if n, err := s.ReadFrom(bytes.NewReader([]byte("submarine"))); n != 9 || err != core.ErrBufferExpired {
+		t.Error("expected ErrBufferExpired after 9 bytes; got", n, err)
+	}
+	read(t, s, []byte("submarine"), nil)
+	read(t, s, nil, io.EOF)
+}
+
+func TestStreamCloseReset(t *testing.T) {
+	s := NewStreamWithOptions(StreamOptions{ChunkSize: 16})
+	write(t, s, make([]byte, 40))
+	c := s.Clone()
+	read(t, s, make([]byte, 5), nil)
+
+	if err := s.Close(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if s.Len() != 0 || s.Size() != 0 {
+		t.Error("chunks not discarded")
+	}
+
+	// Writes fail, reads find nothing.
+	data := []byte("yellow submarine")
+	if n, err := s.Write(data); n != 0 || err != ErrStreamClosed {
+		t.Error("expected ErrStreamClosed; got", n, err)
+	}
+	if !bytes.Equal(data, make([]byte, len(data))) {
+		t.Error("buffer not wiped")
+	}
+	if n, err := s.ReadFrom(bytes.NewReader(make([]byte, 16))); n != 0 || err != ErrStreamClosed {
+		t.Error("expected ErrStreamClosed; got", n, err)
+	}
+	read(t, s, nil, io.EOF)
+	if b, err := s.Flush(); err != nil || b.Size() != 0 {
+		t.Error("expected nothing from flush; got", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Error("unexpected error closing again:", err)
+	}
+	if _, err := s.Clone().Write([]byte("x")); err != ErrStreamClosed {
+		t.Error("expected clone to be closed; got", err)
+	}
+
+	// Clones are unaffected.
+	if c.Size() != 40 {
+		t.Error("clone lost data")
+	}
+	c.Close()
+
+	// Reset reopens the stream.
+	s.Reset()
+	write(t, s, []byte("yellow submarine"))
+	read(t, s, []byte("yellow submarine"), nil)
+	read(t, s, nil, io.EOF)
+
+	// Reset discards data.
+	write(t, s, make([]byte, 40))
+	s.Reset()
+	if s.Size() != 0 {
+		t.Error("data not discarded")
+	}
+	read(t, s, nil, io.EOF)
+}
+
+func TestStreamCoalescing(t *testing.T) {
+	s := NewStreamWithOptions(StreamOptions{ChunkSize: 16})
+	ref := make([]byte, 40)
+	ScrambleBytes(ref)
+
+	// Byte-by-byte writes should be gathered into full chunks.
+	for i := range ref {
+		write(t, s, []byte{ref[i]})
+	}
+	if s.Len() != 2 || s.Size() != len(ref) {
+		t.Error("unexpected chunks", s.Len(), s.Size())
+	}
+	for e := s.Front(); e != nil; e = e.Next() {
+		if e.Value.(*Enclave).Size() != streamHeaderSize+16 {
+			t.Error("expected a full chunk")
+		}
+	}
+
+	// Buffered data is sealed once it is needed.
+	read(t, s, ref[:16], nil)
+	read(t, s, ref[16:32], nil)
+	read(t, s, ref[32:], nil)
+	read(t, s, nil, io.EOF)
+	if s.pending != nil {
+		t.Error("write buffer should have been sealed")
+	}
+}
+
+func TestStreamCompact(t *testing.T) {
+	s := NewStreamWithOptions(StreamOptions{ChunkSize: 16})
+	ref := make([]byte, 50)
+	ScrambleBytes(ref)
+
+	// Make a stream of undersized chunks.
+	for i := 0; i < len(ref); i += 5 {
+		write(t, s, append([]byte{}, ref[i:i+5]...))
+		s.NewReader()
+	}
+	read(t, s, ref[:2], nil)
+	if s.Len() != 10 {
+		t.Error("expected 10 chunks; got", s.Len())
+	}
+
+	c := s.Clone()
+	if err := s.Compact(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if s.Len() != 3 || s.Size() != len(ref)-2 {
+		t.Error("unexpected chunks after compaction", s.Len(), s.Size())
+	}
+	for e := s.Front(); e != nil && e.Next() != nil; e = e.Next() {
+		if e.Value.(*Enclave).Size() != streamHeaderSize+16 {
+			t.Error("expected a full chunk")
+		}
+	}
+	write(t, s, []byte("yellow submarine"))
+	b, err := s.Flush()
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !b.EqualTo(append(append([]byte{}, ref[2:]...), "yellow submarine"...)) {
+		t.Error("unexpected data after compaction")
+	}
+	b.Destroy()
+
+	// Clones are unaffected.
+	b, err = c.Flush()
+	if err != nil || !b.EqualTo(ref[2:]) {
+		t.Error("unexpected data in clone", err)
+	}
+	b.Destroy()
+
+	// Compacting a compact stream does nothing.
+	write(t, s, make([]byte, 40))
+	front := s.Front()
+	if err := s.Compact(); err != nil || s.Front() != front {
+		t.Error("compact stream was modified", err)
+	}
+
+	// Errors leave the stream unchanged.
+	s = NewStreamWithOptions(StreamOptions{ChunkSize: 16})
+	write(t, s, make([]byte, 5))
+	s.NewReader()
+	write(t, s, make([]byte, 5))
+	s.NewReader()
+	s.MoveToBack(s.Front())
+	front = s.Front()
+	if err := s.Compact(); err != ErrStreamCorrupt || s.Front() != front || s.Len() != 2 {
+		t.Error("expected ErrStreamCorrupt with stream unchanged; got", err)
+	}
+}
+
+func TestStreamParallel(t *testing.T) {
+	if NewStream().parallelism != runtime.GOMAXPROCS(0) {
+		t.Error("expected default parallelism of GOMAXPROCS")
+	}
+
+	for _, parallelism := range []int{1, 3, 16} {
+		s := NewStreamWithOptions(StreamOptions{ChunkSize: 16, Parallelism: parallelism})
+		ref := make([]byte, 1000)
+		ScrambleBytes(ref)
+
+		// Top up a partial chunk, then write many chunks at once.
+		write(t, s, append([]byte{}, ref[:10]...))
+		write(t, s, append([]byte{}, ref[10:]...))
+		if s.Len() != 62 || s.Size() != len(ref) {
+			t.Error("unexpected chunks", s.Len(), s.Size())
+		}
+
+		// Chunks come out in order.
+		c := s.Clone()
+		b, err := s.Flush()
+		if err != nil || !b.EqualTo(ref) {
+			t.Error("unexpected data from flush", err)
+		}
+		b.Destroy()
+		var out bytes.Buffer
+		if _, err := c.WriteTo(&out); err != nil || !bytes.Equal(out.Bytes(), ref) {
+			t.Error("unexpected data from WriteTo", err)
+		}
+
+		// Data before a bad chunk is returned, and the bad chunk is discarded.
+		write(t, s, append([]byte{}, ref...))
+		s.Front().Next().Next().Value = NewEnclaveRandom(streamHeaderSize + 16)
+		b, err = s.Flush()
+		if err != ErrStreamCorrupt || !b.EqualTo(ref[:32]) {
+			t.Error("expected first two chunks and ErrStreamCorrupt; got", b.Size(), err)
+		}
+		b.Destroy()
+		if s.Len() != 60 {
+			t.Error("unexpected chunks left", s.Len())
+		}
+		out.Reset()
+		if _, err := s.WriteTo(&out); err != nil || !bytes.Equal(out.Bytes(), ref[48:]) {
+			t.Error("unexpected data after bad chunk", err)
+		}
+
+		// WriteTo stops at a bad chunk too.
+		write(t, s, append([]byte{}, ref...))
+		s.Front().Next().Next().Value = NewEnclaveRandom(streamHeaderSize + 16)
+		out.Reset()
+		if _, err := s.WriteTo(&out); err != ErrStreamCorrupt || !bytes.Equal(out.Bytes(), ref[:32]) {
+			t.Error("expected first two chunks and ErrStreamCorrupt; got", out.Len(), err)
+		}
+		if s.Len() != 60 {
+			t.Error("unexpected chunks left", s.Len())
+		}
+		b, err = s.Flush()
+		if err != nil || !b.EqualTo(ref[48:]) {
+			t.Error("unexpected data after bad chunk", err)
+		}
+		b.Destroy()
+	}
+}
+
 func BenchmarkStreamWrite(b *testing.B) {
 	b.ReportAllocs()
 	b.SetBytes(int64(StreamChunkSize))
@@ -245,3 +871,67 @@ func BenchmarkStreamRead(b *testing.B) {
 
 	runtime.KeepAlive(s)
 }
+
+func BenchmarkStreamWriteSmall(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(16)
+
+	s := NewStream()
+	buf := make([]byte, 16)
+	for i := 0; i < b.N; i++ {
+		s.Write(buf)
+	}
+	runtime.KeepAlive(s)
+}
+
+func BenchmarkStreamCompact(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(StreamChunkSize))
+
+	for i := 0; i < b.N; i++ {
+		// A chunk's worth of data, split across sixteen chunks.
+		b.StopTimer()
+		s := NewStream()
+		buf := make([]byte, StreamChunkSize/16)
+		for j := 0; j < 16; j++ {
+			s.Write(buf)
+			s.NewReader()
+		}
+		b.StartTimer()
+
+		s.Compact()
+	}
+}
+
+func BenchmarkStreamParallel(b *testing.B) {
+	size := 64 * StreamChunkSize
+	for _, parallelism := range []int{1, 4} {
+		b.Run("Write/"+strconv.Itoa(parallelism), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+
+			s := NewStreamWithOptions(StreamOptions{Parallelism: parallelism})
+			buf := make([]byte, size)
+			for i := 0; i < b.N; i++ {
+				s.Write(buf)
+				b.StopTimer()
+				s.Reset()
+				b.StartTimer()
+			}
+		})
+		b.Run("Flush/"+strconv.Itoa(parallelism), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+
+			s := NewStreamWithOptions(StreamOptions{Parallelism: parallelism})
+			buf := make([]byte, size)
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				s.Write(buf)
+				b.StartTimer()
+				d, _ := s.Flush()
+				d.Destroy()
+			}
+		})
+	}
+}
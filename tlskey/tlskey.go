@@ -0,0 +1,231 @@
+/*
+Package tlskey loads TLS certificates whose private keys are kept sealed inside memguard Enclaves.
+
+The private key of the returned tls.Certificate implements crypto.Signer and crypto.Decrypter. It holds only the DER encoding of the key, sealed inside an Enclave, and decrypts and parses it for each handshake that needs it.
+
+The PEM decoding is performed inside locked memory, but the key objects constructed by crypto/x509 live in ordinary memory while a handshake is using them. Their secret values are overwritten as soon as the operation completes, although internal values derived by the standard library cannot be reached and are left to the garbage collector.
+*/
+package tlskey
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+
+	"github.com/awnumar/memguard"
+	"github.com/awnumar/memguard/internal/privkey"
+)
+
+// ErrNoCertificate is returned when the certificate PEM data does not contain any certificates.
+var ErrNoCertificate = errors.New("<memguard::tlskey::ErrNoCertificate> no certificate found in PEM data")
+
+// ErrNoKey is returned when the key PEM data does not contain a private key.
+var ErrNoKey = errors.New("<memguard::tlskey::ErrNoKey> no private key found in PEM data")
+
+// ErrEncryptedKey is returned when the private key is protected with a passphrase, which is not supported.
+var ErrEncryptedKey = errors.New("<memguard::tlskey::ErrEncryptedKey> encrypted PEM private keys are not supported")
+
+// ErrKeyMismatch is returned when the private key does not correspond to the public key of the certificate.
+var ErrKeyMismatch = errors.New("<memguard::tlskey::ErrKeyMismatch> private key does not match public key in certificate")
+
+// ErrNotDecrypter is returned by Decrypt when the private key is not an RSA key.
+var ErrNotDecrypter = errors.New("<memguard::tlskey::ErrNotDecrypter> private key does not support decryption")
+
+/*
+LoadX509KeyPair parses a public/private key pair from PEM encoded data, in the same way as tls.X509KeyPair. The certificate chain is parsed as usual. The private key, which may be in PKCS #1, PKCS #8 or SEC 1 form, is decoded from the LockedBuffer directly into locked memory and sealed into an Enclave. The contents of keyPEM are not modified, so it may be destroyed as soon as this function returns.
+*/
+func LoadX509KeyPair(certPEM []byte, keyPEM *memguard.LockedBuffer) (tls.Certificate, error) {
+	var cert tls.Certificate
+	for {
+		var block *pem.Block
+		block, certPEM = pem.Decode(certPEM)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			cert.Certificate = append(cert.Certificate, block.Bytes)
+		}
+	}
+	if len(cert.Certificate) == 0 {
+		return tls.Certificate{}, ErrNoCertificate
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	cert.Leaf = leaf
+
+	der, kind, err := decodeKey(keyPEM)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	k := &sealedKey{kind: kind}
+
+	// Parse the key once to check that it is valid and that it matches the certificate.
+	priv, err := parseKey(der.Bytes(), kind)
+	if err != nil {
+		der.Destroy()
+		return tls.Certificate{}, err
+	}
+	k.pub = priv.Public()
+	privkey.Wipe(priv)
+	if pub, ok := leaf.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(k.pub) {
+		der.Destroy()
+		return tls.Certificate{}, ErrKeyMismatch
+	}
+
+	k.der = der.Seal()
+	cert.PrivateKey = k
+	return cert, nil
+}
+
+// sealedKey implements crypto.Signer and crypto.Decrypter using the DER encoding of a private key sealed inside an Enclave.
+type sealedKey struct {
+	der  *memguard.Enclave
+	kind string // PEM block type
+	pub  crypto.PublicKey
+}
+
+// Public returns the public key corresponding to the private key.
+func (k *sealedKey) Public() crypto.PublicKey {
+	return k.pub
+}
+
+// Sign opens and parses the private key, signs the digest with it and then wipes the parsed key.
+func (k *sealedKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	priv, err := k.open()
+	if err != nil {
+		return nil, err
+	}
+	defer privkey.Wipe(priv)
+
+	return priv.Sign(rand, digest, opts)
+}
+
+// Decrypt opens and parses the private key, decrypts the message with it and then wipes the parsed key. Only RSA keys support decryption.
+func (k *sealedKey) Decrypt(rand io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	priv, err := k.open()
+	if err != nil {
+		return nil, err
+	}
+	defer privkey.Wipe(priv)
+
+	d, ok := priv.(crypto.Decrypter)
+	if !ok {
+		return nil, ErrNotDecrypter
+	}
+	return d.Decrypt(rand, msg, opts)
+}
+
+// Decrypts and parses the private key.
+func (k *sealedKey) open() (crypto.Signer, error) {
+	der, err := k.der.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer der.Destroy()
+
+	return parseKey(der.Bytes(), k.kind)
+}
+
+// Parses a DER encoded private key of the given PEM block type.
+func parseKey(der []byte, kind string) (crypto.Signer, error) {
+	var key any
+	var err error
+	switch kind {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(der)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(der)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(der)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		privkey.Wipe(key)
+		return nil, ErrNoKey
+	}
+	return signer, nil
+}
+
+/*
+decodeKey finds the first PEM block in a LockedBuffer whose type ends in "PRIVATE KEY" and decodes its body into a new LockedBuffer, returning it along with the block type. The decoding is performed in constant time and all intermediate copies of the key are held in locked memory.
+*/
+func decodeKey(b *memguard.LockedBuffer) (*memguard.LockedBuffer, string, error) {
+	const begin, end, dashes = "-----BEGIN ", "-----END ", "-----"
+
+	b.RLock()
+	defer b.RUnlock()
+
+	data := b.Bytes()
+	for {
+		i := bytes.Index(data, []byte(begin))
+		if i < 0 {
+			return nil, "", ErrNoKey
+		}
+		data = data[i+len(begin):]
+
+		// Read the block type from the header line.
+		j := bytes.Index(data, []byte(dashes))
+		if j < 0 || bytes.IndexByte(data[:j], '\n') >= 0 {
+			return nil, "", ErrNoKey
+		}
+		kind := string(data[:j])
+		data = data[j+len(dashes):]
+
+		// Find the matching footer.
+		footer := []byte(end + kind + dashes)
+		k := bytes.Index(data, footer)
+		if k < 0 {
+			return nil, "", ErrNoKey
+		}
+		body := data[:k]
+		data = data[k+len(footer):]
+
+		if len(kind) < len("PRIVATE KEY") || kind[len(kind)-len("PRIVATE KEY"):] != "PRIVATE KEY" {
+			continue
+		}
+
+		// Base64 never contains a colon, so this is a header such as Proc-Type.
+		if bytes.IndexByte(body, ':') >= 0 {
+			if bytes.Contains(body, []byte("ENCRYPTED")) {
+				return nil, "", ErrEncryptedKey
+			}
+			return nil, "", ErrNoKey
+		}
+
+		// Strip out the line breaks.
+		enc := memguard.NewBuffer(len(body))
+		n := 0
+		for _, c := range body {
+			switch c {
+			case '\n', '\r', ' ', '\t':
+			default:
+				enc.Bytes()[n] = c
+				n++
+			}
+		}
+		if n == 0 {
+			enc.Destroy()
+			return nil, "", ErrNoKey
+		}
+
+		// This wipes the encoded copy.
+		der, err := memguard.NewBufferFromBase64(enc.Bytes()[:n])
+		enc.Destroy()
+		if err != nil {
+			return nil, "", err
+		}
+		return der, kind, nil
+	}
+}
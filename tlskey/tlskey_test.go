@@ -0,0 +1,213 @@
+package tlskey
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/awnumar/memguard"
+)
+
+// Generates a self-signed certificate for the given key, returning the certificate and key in PEM form.
+func generate(t *testing.T, priv crypto.Signer, keyType string) ([]byte, []byte) {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, priv.Public(), priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keyDER []byte
+	switch keyType {
+	case "RSA PRIVATE KEY":
+		keyDER = x509.MarshalPKCS1PrivateKey(priv.(*rsa.PrivateKey))
+	case "EC PRIVATE KEY":
+		keyDER, err = x509.MarshalECPrivateKey(priv.(*ecdsa.PrivateKey))
+	default:
+		keyDER, err = x509.MarshalPKCS8PrivateKey(priv)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		pem.EncodeToMemory(&pem.Block{Type: keyType, Bytes: keyDER})
+}
+
+func TestLoadX509KeyPair(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		key     crypto.Signer
+		keyType string
+	}{
+		{"RSA/PKCS1", rsaKey, "RSA PRIVATE KEY"},
+		{"RSA/PKCS8", rsaKey, "PRIVATE KEY"},
+		{"ECDSA/SEC1", ecKey, "EC PRIVATE KEY"},
+		{"ECDSA/PKCS8", ecKey, "PRIVATE KEY"},
+		{"Ed25519/PKCS8", edKey, "PRIVATE KEY"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			certPEM, keyPEM := generate(t, tc.key, tc.keyType)
+
+			keyBuf := memguard.NewBufferFromBytes(keyPEM)
+			cert, err := LoadX509KeyPair(certPEM, keyBuf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keyBuf.Destroy()
+
+			if _, ok := cert.PrivateKey.(crypto.Signer); !ok {
+				t.Fatal("private key is not a crypto.Signer")
+			}
+			if _, ok := cert.PrivateKey.(crypto.Decrypter); !ok {
+				t.Fatal("private key is not a crypto.Decrypter")
+			}
+			serve(t, cert)
+		})
+	}
+}
+
+// Performs a request against a local TLS server using the given certificate.
+func serve(t *testing.T, cert tls.Certificate) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "yellow submarine")
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:    pool,
+		ServerName: "example.com",
+	}}}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "yellow submarine" {
+		t.Error("unexpected response:", string(body))
+	}
+}
+
+func TestDecrypt(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, keyPEM := generate(t, rsaKey, "PRIVATE KEY")
+	cert, err := LoadX509KeyPair(certPEM, memguard.NewBufferFromBytes(keyPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := cert.PrivateKey.(crypto.Decrypter)
+
+	ct, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, &rsaKey.PublicKey, []byte("yellow submarine"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := d.Decrypt(rand.Reader, ct, &rsa.OAEPOptions{Hash: crypto.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(pt) != "yellow submarine" {
+		t.Error("decrypted plaintext does not match")
+	}
+
+	// Non-RSA keys cannot decrypt.
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, keyPEM = generate(t, ecKey, "PRIVATE KEY")
+	cert, err = LoadX509KeyPair(certPEM, memguard.NewBufferFromBytes(keyPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cert.PrivateKey.(crypto.Decrypter).Decrypt(rand.Reader, ct, nil); err != ErrNotDecrypter {
+		t.Error("expected ErrNotDecrypter; got", err)
+	}
+}
+
+func TestLoadX509KeyPairErrors(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, keyPEM := generate(t, ecKey, "PRIVATE KEY")
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPEM := generate(t, otherKey, "PRIVATE KEY")
+
+	encrypted := pem.EncodeToMemory(&pem.Block{
+		Type:    "RSA PRIVATE KEY",
+		Headers: map[string]string{"Proc-Type": "4,ENCRYPTED", "DEK-Info": "AES-128-CBC,00000000000000000000000000000000"},
+		Bytes:   []byte("yellow submarine"),
+	})
+
+	for _, tc := range []struct {
+		name string
+		cert []byte
+		key  []byte
+		err  error
+	}{
+		{"no certificate", keyPEM, keyPEM, ErrNoCertificate},
+		{"no key", certPEM, certPEM, ErrNoKey},
+		{"mismatch", certPEM, otherPEM, ErrKeyMismatch},
+		{"encrypted", certPEM, encrypted, ErrEncryptedKey},
+	} {
+		if _, err := LoadX509KeyPair(tc.cert, memguard.NewBufferFromBytes(append([]byte{}, tc.key...))); err != tc.err {
+			t.Errorf("%s: expected %v; got %v", tc.name, tc.err, err)
+		}
+	}
+
+	// Parameters blocks before the key should be skipped.
+	params := pem.EncodeToMemory(&pem.Block{Type: "EC PARAMETERS", Bytes: []byte{6, 8, 42, 134, 72, 206, 61, 3, 1, 7}})
+	if _, err := LoadX509KeyPair(certPEM, memguard.NewBufferFromBytes(append(params, keyPEM...))); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package transfer
+
+import "net"
+
+// Peer credentials are not implemented on this platform.
+func peerCred(conn *net.UnixConn) (PeerCred, error) {
+	return PeerCred{}, ErrPeerCredUnsupported
+}
@@ -0,0 +1,319 @@
+/*
+Package transfer sends the contents of enclaves between processes on the same machine over Unix domain sockets.
+
+Before any data is exchanged each end checks the credentials of the process on the other end of the socket, as reported by the kernel. By default the peer must be running as the same user. The two ends then perform an ephemeral X25519 key agreement and the secret is sent in XChaCha20-Poly1305 encrypted frames. The plaintext is only ever read from and written to LockedBuffers.
+
+	// In the receiving process.
+	conn, err := listener.AcceptUnix()
+	secret, err := transfer.Receive(conn, transfer.Options{})
+
+	// In the sending process.
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"})
+	err = transfer.Send(conn, secret, transfer.Options{})
+
+Peer credential checks are currently only supported on Linux. On other platforms an Authorize function that accepts ErrPeerCredUnsupported must be provided.
+*/
+package transfer
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+
+	"github.com/awnumar/memguard"
+	"github.com/awnumar/memguard/core"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+)
+
+// ErrUnauthorized is returned by the default authorization check when the peer is running as a different user.
+var ErrUnauthorized = errors.New("<memguard::transfer::ErrUnauthorized> peer is not authorized")
+
+// ErrPeerCredUnsupported is passed to Authorize functions on platforms where the credentials of the peer cannot be determined.
+var ErrPeerCredUnsupported = errors.New("<memguard::transfer::ErrPeerCredUnsupported> peer credentials are not supported on this platform")
+
+// ErrHandshake is returned when the peer does not speak the protocol or sends an invalid public key.
+var ErrHandshake = errors.New("<memguard::transfer::ErrHandshake> handshake failed")
+
+// ErrCorrupt is returned when a frame fails authentication or the stream of frames is malformed.
+var ErrCorrupt = errors.New("<memguard::transfer::ErrCorrupt> received data is corrupt")
+
+// ErrTooLarge is returned when the peer attempts to send more data than the receiver allows.
+var ErrTooLarge = errors.New("<memguard::transfer::ErrTooLarge> secret exceeds the maximum size")
+
+// DefaultMaxSize is the largest secret that will be received if Options.MaxSize is not set.
+const DefaultMaxSize = 16 << 20
+
+const (
+	magic     = "MGT1" // protocol identifier and version
+	label     = "memguard/transfer/v1"
+	chunkSize = 16 << 10 // maximum plaintext size of a data frame
+	maxFrame  = chunkSize + chacha20poly1305.Overhead
+)
+
+// Frame types, used as additional data so that frames cannot be substituted for one another.
+const (
+	frameHeader byte = iota
+	frameData
+	frameAck
+)
+
+// PeerCred holds the credentials of the process at the other end of a socket.
+type PeerCred struct {
+	PID int
+	UID int
+	GID int
+}
+
+// Options configures a transfer. The zero value is suitable for most uses.
+type Options struct {
+	// Authorize decides whether the peer may take part in the transfer. If the credentials could not be determined, err is non-nil. If Authorize is nil, the peer must be running as the same user as the current process.
+	Authorize func(cred PeerCred, err error) error
+
+	// MaxSize is the largest secret that Receive will accept. If it is zero, DefaultMaxSize is used.
+	MaxSize int
+}
+
+/*
+Send decrypts an Enclave and sends its contents over a Unix domain socket to a peer calling Receive. It returns once the peer has acknowledged receipt. The Enclave is left untouched.
+*/
+func Send(conn *net.UnixConn, e *memguard.Enclave, opts Options) error {
+	b, err := e.Open()
+	if err != nil {
+		return err
+	}
+	defer b.Destroy()
+
+	return SendBuffer(conn, b, opts)
+}
+
+/*
+SendBuffer sends the contents of a LockedBuffer over a Unix domain socket to a peer calling Receive. It returns once the peer has acknowledged receipt.
+*/
+func SendBuffer(conn *net.UnixConn, b *memguard.LockedBuffer, opts Options) error {
+	s, err := handshake(conn, opts, true)
+	if err != nil {
+		return err
+	}
+
+	// Announce the size of the secret.
+	var header [8]byte
+	binary.BigEndian.PutUint64(header[:], uint64(b.Size()))
+	if err := s.writeFrame(frameHeader, header[:]); err != nil {
+		return err
+	}
+
+	// Send the data in chunks, encrypting straight out of the buffer.
+	b.RLock()
+	for off := 0; off < b.Size(); off += chunkSize {
+		end := off + chunkSize
+		if end > b.Size() {
+			end = b.Size()
+		}
+		if err := s.writeFrame(frameData, b.Bytes()[off:end]); err != nil {
+			b.RUnlock()
+			return err
+		}
+	}
+	b.RUnlock()
+
+	// Wait for the receiver to confirm it got everything.
+	if _, err := s.readFrame(frameAck, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+/*
+Receive accepts a secret sent by a peer calling Send and returns it sealed inside an Enclave. An empty secret results in a nil Enclave.
+*/
+func Receive(conn *net.UnixConn, opts Options) (*memguard.Enclave, error) {
+	b, err := ReceiveBuffer(conn, opts)
+	if err != nil {
+		return nil, err
+	}
+	return b.Seal(), nil
+}
+
+/*
+ReceiveBuffer accepts a secret sent by a peer calling Send and returns it inside an immutable LockedBuffer. Each frame is decrypted directly into the buffer.
+*/
+func ReceiveBuffer(conn *net.UnixConn, opts Options) (*memguard.LockedBuffer, error) {
+	s, err := handshake(conn, opts, false)
+	if err != nil {
+		return memguard.NewBuffer(0), err
+	}
+
+	maxSize := opts.MaxSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	// Find out how large the secret is.
+	var header [8]byte
+	n, err := s.readFrame(frameHeader, header[:])
+	if err != nil {
+		return memguard.NewBuffer(0), err
+	}
+	if n != len(header) {
+		return memguard.NewBuffer(0), ErrCorrupt
+	}
+	size := binary.BigEndian.Uint64(header[:])
+	if size > uint64(maxSize) {
+		return memguard.NewBuffer(0), ErrTooLarge
+	}
+
+	b := memguard.NewBuffer(int(size))
+	for off := 0; off < b.Size(); {
+		n, err := s.readFrame(frameData, b.Bytes()[off:])
+		if err != nil {
+			b.Destroy()
+			return memguard.NewBuffer(0), err
+		}
+		if n == 0 {
+			b.Destroy()
+			return memguard.NewBuffer(0), ErrCorrupt
+		}
+		off += n
+	}
+
+	if err := s.writeFrame(frameAck, nil); err != nil {
+		b.Destroy()
+		return memguard.NewBuffer(0), err
+	}
+
+	b.Freeze()
+	return b, nil
+}
+
+// session holds the state of an established connection.
+type session struct {
+	conn           *net.UnixConn
+	sender         bool
+	aead           cipher.AEAD
+	sent, received uint64 // frame counters for each direction
+}
+
+// Checks the peer's credentials, exchanges ephemeral public keys and derives the session key.
+func handshake(conn *net.UnixConn, opts Options, sender bool) (*session, error) {
+	if err := authorize(conn, opts); err != nil {
+		return nil, err
+	}
+
+	// Generate our ephemeral key pair.
+	priv := memguard.NewBufferRandom(curve25519.ScalarSize)
+	defer priv.Destroy()
+	var pub [curve25519.PointSize]byte
+	curve25519.ScalarBaseMult(&pub, priv.ByteArray32())
+
+	// Exchange public keys.
+	if _, err := conn.Write(append([]byte(magic), pub[:]...)); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, len(magic)+curve25519.PointSize)
+	if _, err := io.ReadFull(conn, msg); err != nil {
+		return nil, err
+	}
+	if string(msg[:len(magic)]) != magic {
+		return nil, ErrHandshake
+	}
+	var peer [curve25519.PointSize]byte
+	copy(peer[:], msg[len(magic):])
+
+	// Lay out the key derivation input: shared secret || label || sender public key || receiver public key.
+	kdf := memguard.NewBuffer(curve25519.PointSize + len(label) + 2*curve25519.PointSize)
+	defer kdf.Destroy()
+	curve25519.ScalarMult((*[32]byte)(kdf.Bytes()[:32]), priv.ByteArray32(), &peer)
+	if core.Equal(kdf.Bytes()[:32], make([]byte, 32)) {
+		return nil, ErrHandshake // peer sent a low-order point
+	}
+	copy(kdf.Bytes()[32:], label)
+	ours, theirs := kdf.Bytes()[32+len(label):][:32], kdf.Bytes()[32+len(label)+32:]
+	if !sender {
+		ours, theirs = theirs, ours
+	}
+	copy(ours, pub[:])
+	copy(theirs, peer[:])
+
+	// Derive the session key.
+	key := memguard.NewBuffer(32)
+	defer key.Destroy()
+	sum := blake2b.Sum256(kdf.Bytes())
+	key.Move(sum[:])
+
+	aead, err := chacha20poly1305.NewX(key.Bytes())
+	if err != nil {
+		core.Panic(err) // key is not 32 bytes long
+	}
+
+	return &session{conn: conn, sender: sender, aead: aead}, nil
+}
+
+// Constructs the nonce for the next frame in one direction. Nonces never repeat as each session has a fresh key.
+func (s *session) nonce(fromSender bool, counter uint64) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if fromSender {
+		nonce[0] = 1
+	}
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], counter)
+	return nonce
+}
+
+// Encrypts and writes a single frame.
+func (s *session) writeFrame(kind byte, plaintext []byte) error {
+	nonce := s.nonce(s.sender, s.sent)
+	s.sent++
+
+	frame := make([]byte, 4, 4+len(plaintext)+chacha20poly1305.Overhead)
+	frame = s.aead.Seal(frame, nonce, plaintext, []byte{kind})
+	binary.BigEndian.PutUint32(frame, uint32(len(frame)-4))
+
+	_, err := s.conn.Write(frame)
+	return err
+}
+
+// Reads and decrypts a single frame of the given type into dst, returning the length of the plaintext. The frame must fit inside dst.
+func (s *session) readFrame(kind byte, dst []byte) (int, error) {
+	nonce := s.nonce(!s.sender, s.received)
+	s.received++
+
+	var length [4]byte
+	if _, err := io.ReadFull(s.conn, length[:]); err != nil {
+		return 0, err
+	}
+	n := int(binary.BigEndian.Uint32(length[:]))
+	if n < chacha20poly1305.Overhead || n > maxFrame || n-chacha20poly1305.Overhead > len(dst) {
+		return 0, ErrCorrupt
+	}
+
+	ciphertext := make([]byte, n)
+	if _, err := io.ReadFull(s.conn, ciphertext); err != nil {
+		return 0, err
+	}
+
+	// Decrypt in place into the destination; it is wiped on failure.
+	plaintext, err := s.aead.Open(dst[:0], nonce, ciphertext, []byte{kind})
+	if err != nil {
+		return 0, ErrCorrupt
+	}
+	return len(plaintext), nil
+}
+
+// Runs the authorization check on the peer's credentials.
+func authorize(conn *net.UnixConn, opts Options) error {
+	cred, err := peerCred(conn)
+	if opts.Authorize != nil {
+		return opts.Authorize(cred, err)
+	}
+	if err != nil {
+		return err
+	}
+	if cred.UID != os.Getuid() {
+		return ErrUnauthorized
+	}
+	return nil
+}
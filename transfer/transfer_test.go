@@ -0,0 +1,226 @@
+package transfer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/awnumar/memguard"
+)
+
+// Returns a connected pair of Unix domain sockets.
+func socketPair(t *testing.T) (*net.UnixConn, *net.UnixConn) {
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: filepath.Join(t.TempDir(), "sock"), Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	accepted := make(chan *net.UnixConn)
+	go func() {
+		conn, err := l.AcceptUnix()
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- conn
+	}()
+
+	client, err := net.DialUnix("unix", nil, l.Addr().(*net.UnixAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := <-accepted
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+// Sends a secret in the background and returns a channel that yields the result.
+func sendAsync(conn *net.UnixConn, data []byte, opts Options) chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- Send(conn, memguard.NewEnclave(data), opts)
+	}()
+	return done
+}
+
+// Accepts any peer; peer credentials are not available on every platform.
+func permissive() Options {
+	if runtime.GOOS == "linux" {
+		return Options{}
+	}
+	return Options{Authorize: func(PeerCred, error) error { return nil }}
+}
+
+func TestSendReceive(t *testing.T) {
+	for _, size := range []int{1, 32, chunkSize, chunkSize + 1, 5*chunkSize + 123} {
+		client, server := socketPair(t)
+
+		data := make([]byte, size)
+		memguard.ScrambleBytes(data)
+		ref := make([]byte, size)
+		copy(ref, data)
+
+		done := sendAsync(client, data, permissive())
+		b, err := ReceiveBuffer(server, permissive())
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		if !b.EqualTo(ref) {
+			t.Error("data does not match for size", size)
+		}
+		if b.IsMutable() {
+			t.Error("buffer should be immutable")
+		}
+		b.Destroy()
+
+		if err := <-done; err != nil {
+			t.Error("unexpected error sending:", err)
+		}
+	}
+}
+
+func TestReceiveEnclave(t *testing.T) {
+	client, server := socketPair(t)
+
+	done := sendAsync(client, []byte("yellow submarine"), permissive())
+	e, err := Receive(server, permissive())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	b, err := e.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.EqualTo([]byte("yellow submarine")) {
+		t.Error("data does not match")
+	}
+	b.Destroy()
+	if err := <-done; err != nil {
+		t.Error("unexpected error sending:", err)
+	}
+
+	// Empty secrets are allowed.
+	client, server = socketPair(t)
+	sent := make(chan error, 1)
+	go func() {
+		sent <- SendBuffer(client, memguard.NewBuffer(0), permissive())
+	}()
+	e, err = Receive(server, permissive())
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if e != nil {
+		t.Error("expected nil enclave")
+	}
+	if err := <-sent; err != nil {
+		t.Error("unexpected error sending:", err)
+	}
+}
+
+func TestPeerCred(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials unsupported")
+	}
+	client, server := socketPair(t)
+
+	var seen PeerCred
+	opts := Options{Authorize: func(cred PeerCred, err error) error {
+		if err != nil {
+			return err
+		}
+		seen = cred
+		return errors.New("denied")
+	}}
+
+	done := sendAsync(client, []byte("yellow submarine"), Options{})
+	b, err := ReceiveBuffer(server, opts)
+	if err == nil || err.Error() != "denied" {
+		t.Error("expected authorization to fail; got", err)
+	}
+	if b.IsAlive() {
+		t.Error("buffer should be destroyed")
+	}
+	if seen.PID == 0 {
+		t.Error("peer credentials not populated")
+	}
+
+	// The sender gives up once the receiver hangs up.
+	server.Close()
+	if err := <-done; err == nil {
+		t.Error("expected send to fail")
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	client, server := socketPair(t)
+
+	done := sendAsync(client, make([]byte, 100), permissive())
+	opts := permissive()
+	opts.MaxSize = 99
+	if _, err := ReceiveBuffer(server, opts); err != ErrTooLarge {
+		t.Error("expected ErrTooLarge; got", err)
+	}
+	server.Close()
+	<-done
+}
+
+func TestHandshake(t *testing.T) {
+	// Wrong protocol.
+	client, server := socketPair(t)
+	go client.Write(bytes.Repeat([]byte{'x'}, len(magic)+32))
+	if _, err := ReceiveBuffer(server, permissive()); err != ErrHandshake {
+		t.Error("expected ErrHandshake; got", err)
+	}
+
+	// Low order point.
+	client, server = socketPair(t)
+	go client.Write(append([]byte(magic), make([]byte, 32)...))
+	if _, err := ReceiveBuffer(server, permissive()); err != ErrHandshake {
+		t.Error("expected ErrHandshake; got", err)
+	}
+}
+
+func TestTampering(t *testing.T) {
+	// Relay traffic between a sender and a receiver, corrupting the first data frame.
+	sender, relayIn := socketPair(t)
+	relayOut, receiver := socketPair(t)
+
+	go io.Copy(relayIn, relayOut)
+	go func() {
+		// Pass the handshake and header frame through untouched.
+		skip := len(magic) + 32 + 4 + 8 + 16
+		buf := make([]byte, skip)
+		if _, err := io.ReadFull(relayIn, buf); err != nil {
+			return
+		}
+		relayOut.Write(buf)
+
+		// Flip a bit in the next frame's ciphertext.
+		buf = make([]byte, 8)
+		if _, err := io.ReadFull(relayIn, buf); err != nil {
+			return
+		}
+		buf[6] ^= 1
+		relayOut.Write(buf)
+		io.Copy(relayOut, relayIn)
+	}()
+
+	done := sendAsync(sender, []byte("yellow submarine"), permissive())
+	b, err := ReceiveBuffer(receiver, permissive())
+	if err != ErrCorrupt {
+		t.Error("expected ErrCorrupt; got", err)
+	}
+	if b.IsAlive() {
+		t.Error("buffer should be destroyed")
+	}
+	receiver.Close()
+	relayIn.Close()
+	<-done
+}
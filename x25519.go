@@ -0,0 +1,50 @@
+package memguard
+
+import (
+	"github.com/awnumar/memguard/core"
+)
+
+// The Curve25519 base point, u = 9.
+var x25519Basepoint = []byte{9, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+/*
+GenerateX25519 generates a new X25519 key pair. The 32 byte private scalar is generated inside a LockedBuffer and returned sealed inside an Enclave, along with the corresponding public key.
+*/
+func GenerateX25519() (priv *Enclave, pub []byte) {
+	b := NewBufferRandom(32)
+
+	pub = make([]byte, 32)
+	if err := x25519(pub, b.Bytes(), x25519Basepoint); err != nil {
+		core.Panic(err) // the base point is not of low order
+	}
+
+	return b.Seal(), pub
+}
+
+/*
+X25519 performs an X25519 key agreement between a private scalar held inside an Enclave and a peer's 32 byte public key. The shared secret is computed directly into a LockedBuffer which is sealed into the returned Enclave. Every intermediate value is held in locked memory and destroyed before the function returns.
+
+If the peer's public key is not 32 bytes long or is of low order, core.ErrInvalidPoint is returned. If the Enclave does not contain a 32 byte scalar, core.ErrInvalidKeyLength is returned.
+*/
+func X25519(priv *Enclave, peerPub []byte) (*Enclave, error) {
+	k, err := priv.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer k.Destroy()
+
+	shared := NewBuffer(32)
+	if err := x25519(shared.Bytes(), k.Bytes(), peerPub); err != nil {
+		shared.Destroy()
+		return nil, err
+	}
+	return shared.Seal(), nil
+}
+
+// Computes X25519 with the field elements held in a temporary LockedBuffer.
+func x25519(dst, scalar, point []byte) error {
+	scratch := NewBuffer(8 * core.X25519ScratchSize)
+	defer scratch.Destroy()
+
+	return core.X25519(dst, scalar, point, scratch.Uint64())
+}
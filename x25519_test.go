@@ -0,0 +1,69 @@
+package memguard
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/awnumar/memguard/core"
+	"golang.org/x/crypto/curve25519"
+)
+
+func TestX25519(t *testing.T) {
+	alice, alicePub := GenerateX25519()
+	bob, bobPub := GenerateX25519()
+	if len(alicePub) != 32 || alice.Size() != 32 {
+		t.Error("unexpected key sizes")
+	}
+
+	// The public key should match the reference implementation.
+	b, err := alice.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := curve25519.X25519(b.Bytes(), curve25519.Basepoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(alicePub, want) {
+		t.Error("public key does not match reference implementation")
+	}
+
+	// Both sides should agree on the shared secret.
+	x, err := X25519(alice, bobPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := X25519(bob, alicePub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eq, err := x.Equal(y); err != nil || !eq {
+		t.Error("shared secrets do not match:", err)
+	}
+
+	// And it should match the reference implementation.
+	want, err = curve25519.X25519(b.Bytes(), bobPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Destroy()
+	s, err := x.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.EqualTo(want) {
+		t.Error("shared secret does not match reference implementation")
+	}
+	s.Destroy()
+
+	// Low order and malformed public keys are rejected.
+	if _, err := X25519(alice, make([]byte, 32)); err != core.ErrInvalidPoint {
+		t.Error("expected ErrInvalidPoint; got", err)
+	}
+	if _, err := X25519(alice, bobPub[:31]); err != core.ErrInvalidPoint {
+		t.Error("expected ErrInvalidPoint; got", err)
+	}
+	if _, err := X25519(NewEnclaveRandom(16), bobPub); err != core.ErrInvalidKeyLength {
+		t.Error("expected ErrInvalidKeyLength; got", err)
+	}
+}